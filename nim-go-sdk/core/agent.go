@@ -37,6 +37,13 @@ type Capabilities struct {
 
 	// SystemPrompt is the system prompt for the agent.
 	SystemPrompt string
+
+	// PromptCaching enables Claude prompt caching for this agent.
+	PromptCaching bool
+
+	// ThinkingBudget enables extended thinking with the given token
+	// budget, at least 1024. Zero disables it.
+	ThinkingBudget int64
 }
 
 // Input represents the input to an agent run.
@@ -44,6 +51,9 @@ type Input struct {
 	// UserMessage is the user's message to process.
 	UserMessage string
 
+	// Attachments are images and documents sent with UserMessage.
+	Attachments []AttachmentContent
+
 	// Context contains user identity, preferences, and execution limits.
 	Context *Context
 
@@ -63,8 +73,16 @@ type Output struct {
 	Text string
 
 	// PendingAction is set when Type is OutputConfirmationNeeded.
+	// It is the first of PendingActions.
 	PendingAction *PendingAction
 
+	// PendingActions lists every write awaiting confirmation, in block order.
+	PendingActions []*PendingAction
+
+	// ToolResults contains results for the other tool_use blocks in the
+	// paused turn, to be sent back together with the confirmed results.
+	ToolResults []ToolResultContent
+
 	// ToolsUsed records all tools invoked during this run.
 	ToolsUsed []ToolExecution
 
@@ -90,6 +108,10 @@ const (
 
 	// OutputError indicates an error occurred.
 	OutputError
+
+	// OutputInterrupted indicates the run was cancelled before it finished.
+	// Text holds the text produced before the interruption.
+	OutputInterrupted
 )
 
 // DefaultCapabilities returns sensible default capabilities.
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Supported attachment media types.
+const (
+	MediaTypeJPEG = "image/jpeg"
+	MediaTypePNG  = "image/png"
+	MediaTypeGIF  = "image/gif"
+	MediaTypeWebP = "image/webp"
+	MediaTypePDF  = "application/pdf"
+	MediaTypeText = "text/plain"
+)
+
+// AttachmentContent is an image or document sent with a user message, such
+// as a receipt photo or a PDF bank statement. Exactly one of Data and URL
+// is set; plain text documents only support Data.
+type AttachmentContent struct {
+	// MediaType is the MIME type, such as "image/png" or "application/pdf".
+	MediaType string `json:"media_type"`
+
+	// Data is the base64-encoded content.
+	Data string `json:"data,omitempty"`
+
+	// URL references a file uploaded elsewhere, which Claude fetches.
+	URL string `json:"url,omitempty"`
+
+	// Name is the original file name, if known.
+	Name string `json:"name,omitempty"`
+}
+
+// IsImage reports whether the attachment is an image.
+func (a *AttachmentContent) IsImage() bool {
+	return strings.HasPrefix(a.MediaType, "image/")
+}
+
+// NewAttachmentBlock creates an image or document content block, depending
+// on the attachment's media type.
+func NewAttachmentBlock(attachment AttachmentContent) ContentBlock {
+	blockType := DocumentBlockType
+	if attachment.IsImage() {
+		blockType = ImageBlockType
+	}
+	return ContentBlock{Type: blockType, Attachment: &attachment}
+}
+
+// NewUserMessageWithAttachments creates a user message with attachments
+// followed by text. Without attachments it is a plain text message.
+func NewUserMessageWithAttachments(text string, attachments []AttachmentContent) Message {
+	if len(attachments) == 0 {
+		return NewUserMessage(text)
+	}
+	blocks := make([]ContentBlock, 0, len(attachments)+1)
+	for _, attachment := range attachments {
+		blocks = append(blocks, NewAttachmentBlock(attachment))
+	}
+	if text != "" {
+		blocks = append(blocks, NewTextBlock(text))
+	}
+	return Message{Role: RoleUser, ContentBlocks: blocks}
+}
+
+// AttachmentLimits restricts the attachments accepted with a message.
+type AttachmentLimits struct {
+	// MaxAttachments is the maximum number of attachments per message.
+	MaxAttachments int
+
+	// MaxImageBytes is the maximum decoded size of an image.
+	MaxImageBytes int
+
+	// MaxDocumentBytes is the maximum decoded size of a document.
+	MaxDocumentBytes int
+
+	// MaxTotalBytes is the maximum combined decoded size of a message's
+	// inline attachments. Attachments are base64 encoded in the request to
+	// Claude, so this keeps a message within the API's request size limit.
+	// Zero means only the per-attachment limits apply.
+	MaxTotalBytes int
+
+	// MediaTypes lists the accepted media types. Claude supports
+	// MediaTypeJPEG, MediaTypePNG, MediaTypeGIF, MediaTypeWebP, MediaTypePDF
+	// and MediaTypeText.
+	MediaTypes []string
+}
+
+// DefaultAttachmentLimits returns limits within what the Claude API accepts.
+func DefaultAttachmentLimits() *AttachmentLimits {
+	return &AttachmentLimits{
+		MaxAttachments:   5,
+		MaxImageBytes:    5 << 20,
+		MaxDocumentBytes: 10 << 20,
+		MaxTotalBytes:    16 << 20, // About 21 MB once encoded, under the 32 MB request limit
+		MediaTypes: []string{
+			MediaTypeJPEG, MediaTypePNG, MediaTypeGIF, MediaTypeWebP,
+			MediaTypePDF, MediaTypeText,
+		},
+	}
+}
+
+// Validate checks attachments against the limits. The size of attachments
+// referenced by URL cannot be checked.
+func (l *AttachmentLimits) Validate(attachments []AttachmentContent) error {
+	if len(attachments) > l.MaxAttachments {
+		return fmt.Errorf("too many attachments: %d, at most %d allowed", len(attachments), l.MaxAttachments)
+	}
+	total := 0
+	for i := range attachments {
+		a := &attachments[i]
+		if !l.allows(a.MediaType) {
+			return fmt.Errorf("attachment %s: unsupported type %q", a.label(i), a.MediaType)
+		}
+		if (a.Data == "") == (a.URL == "") {
+			return fmt.Errorf("attachment %s: exactly one of data and url is required", a.label(i))
+		}
+		if a.URL != "" {
+			if a.MediaType == MediaTypeText {
+				return fmt.Errorf("attachment %s: text documents must be sent as data", a.label(i))
+			}
+			if !strings.HasPrefix(a.URL, "https://") {
+				return fmt.Errorf("attachment %s: url must use https", a.label(i))
+			}
+			continue
+		}
+
+		max := l.MaxDocumentBytes
+		if a.IsImage() {
+			max = l.MaxImageBytes
+		}
+		decoded, err := base64.StdEncoding.DecodeString(a.Data)
+		if err != nil {
+			return fmt.Errorf("attachment %s: invalid base64 data", a.label(i))
+		}
+		if len(decoded) > max {
+			return fmt.Errorf("attachment %s: larger than %d bytes", a.label(i), max)
+		}
+		total += len(decoded)
+		if l.MaxTotalBytes > 0 && total > l.MaxTotalBytes {
+			return fmt.Errorf("attachments larger than %d bytes in total", l.MaxTotalBytes)
+		}
+	}
+	return nil
+}
+
+// MaxEncodedBytes returns the largest base64 encoded size of the inline
+// attachments one message can carry within these limits.
+func (l *AttachmentLimits) MaxEncodedBytes() int64 {
+	total := int64(max(l.MaxImageBytes, l.MaxDocumentBytes)) * int64(l.MaxAttachments)
+	if l.MaxTotalBytes > 0 && int64(l.MaxTotalBytes) < total {
+		total = int64(l.MaxTotalBytes)
+	}
+	return (total + 2) / 3 * 4
+}
+
+// allows reports whether the media type is accepted.
+func (l *AttachmentLimits) allows(mediaType string) bool {
+	for _, t := range l.MediaTypes {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// label names an attachment in validation errors.
+func (a *AttachmentContent) label(i int) string {
+	if a.Name != "" {
+		return fmt.Sprintf("%q", a.Name)
+	}
+	return fmt.Sprintf("%d", i+1)
+}
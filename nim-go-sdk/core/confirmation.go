@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ConfirmationLevel is how much user approval a tool call needs.
+type ConfirmationLevel string
+
+const (
+	// ConfirmationNone executes the call without asking the user. Agents
+	// that cannot request confirmation still never run tools that
+	// require it.
+	ConfirmationNone ConfirmationLevel = "none"
+
+	// ConfirmationRequired asks the user to confirm before executing.
+	ConfirmationRequired ConfirmationLevel = "confirm"
+
+	// ConfirmationStepUp asks the user to confirm with additional
+	// verification, such as a PIN or biometric check.
+	ConfirmationStepUp ConfirmationLevel = "step_up"
+)
+
+// ConfirmationDecision is the result of a ConfirmationPolicy. A zero
+// decision expresses no opinion, leaving the choice to the next policy.
+type ConfirmationDecision struct {
+	// Level is the approval required. Empty for no opinion.
+	Level ConfirmationLevel
+
+	// Reason explains the decision, such as "new recipient". Recorded in
+	// the audit log and shown with the confirmation.
+	Reason string
+}
+
+// ConfirmationCall describes a tool call awaiting a confirmation decision.
+type ConfirmationCall struct {
+	// Tool is the tool being called.
+	Tool Tool
+
+	// Input is the tool input as JSON.
+	Input json.RawMessage
+
+	// Context is the execution context of the agent making the call.
+	Context *Context
+
+	// Details describes the action in structured form, if the tool
+	// implements ActionDescriber. May be nil.
+	Details *ActionDetails
+}
+
+// ConfirmationPolicy decides at call time whether a tool call needs user
+// approval, for example to waive confirmation for small deposits or to
+// always confirm payments to new recipients. Returning a zero decision
+// defers to the next policy and, finally, to Tool.RequiresConfirmation.
+type ConfirmationPolicy func(ctx context.Context, call *ConfirmationCall) ConfirmationDecision
+
+// ConfirmationPolicyProvider is implemented by tools that carry their own
+// confirmation policy.
+type ConfirmationPolicyProvider interface {
+	ConfirmationPolicy() ConfirmationPolicy
+}
+
+// StaticConfirmation returns the decision implied by a tool's
+// RequiresConfirmation, used when no policy expresses an opinion.
+func StaticConfirmation(tool Tool) ConfirmationDecision {
+	if tool.RequiresConfirmation() {
+		return ConfirmationDecision{Level: ConfirmationRequired}
+	}
+	return ConfirmationDecision{Level: ConfirmationNone}
+}
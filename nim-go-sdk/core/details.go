@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Action types for ActionDetails.ActionType.
+const (
+	ActionTypeSend     = "send"
+	ActionTypeDeposit  = "deposit"
+	ActionTypeWithdraw = "withdraw"
+)
+
+// ActionDetails describes a pending write in structured form, so clients
+// can render a confirmation card instead of parsing the summary.
+type ActionDetails struct {
+	// ActionType is what the action does, such as ActionTypeSend.
+	ActionType string `json:"action_type"`
+
+	// Amount is the amount moved, as given in the tool input.
+	Amount string `json:"amount,omitempty"`
+
+	// Currency is the currency of Amount.
+	Currency string `json:"currency,omitempty"`
+
+	// Recipient is the recipient as given in the tool input, usually a
+	// display tag such as @alice.
+	Recipient string `json:"recipient,omitempty"`
+
+	// RecipientUserID is the recipient's resolved user ID, if known.
+	RecipientUserID string `json:"recipient_user_id,omitempty"`
+
+	// ProjectedBalance is the wallet balance in Currency after the action,
+	// if known.
+	ProjectedBalance string `json:"projected_balance,omitempty"`
+}
+
+// ActionDescriber is implemented by tools that can describe their pending
+// actions in structured form. The engine calls DescribeAction when a write
+// is proposed and attaches the result to the PendingAction.
+type ActionDescriber interface {
+	// DescribeAction returns details for the action, or nil if the tool has
+	// none. On error any details gathered so far may still be returned.
+	DescribeAction(ctx context.Context, params *ToolParams) (*ActionDetails, error)
+}
+
+// PreflightFunc completes action details with reads made before the user
+// confirms, such as resolving the recipient or projecting the balance.
+type PreflightFunc func(ctx context.Context, params *ToolParams, details *ActionDetails) error
+
+// ActionDetailsFromInput builds details of the given action type from the
+// amount, currency and recipient properties of a tool input.
+func ActionDetailsFromInput(actionType string, input json.RawMessage) *ActionDetails {
+	details := &ActionDetails{ActionType: actionType}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return details
+	}
+	details.Amount = inputString(fields["amount"])
+	details.Currency = inputString(fields["currency"])
+	details.Recipient = inputString(fields["recipient"])
+	return details
+}
+
+// describeAction implements DescribeAction for tools built from a
+// ToolDefinition.
+func describeAction(ctx context.Context, def ToolDefinition, params *ToolParams) (*ActionDetails, error) {
+	if def.ActionType == "" {
+		return nil, nil
+	}
+	details := ActionDetailsFromInput(def.ActionType, params.Input)
+	if def.Preflight != nil {
+		if err := def.Preflight(ctx, params, details); err != nil {
+			return details, fmt.Errorf("preflight for %s: %w", def.ToolName, err)
+		}
+	}
+	return details, nil
+}
+
+// inputString formats a string or number input value.
+func inputString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%g", v)
+	}
+	return fmt.Sprint(v)
+}
@@ -69,6 +69,21 @@ type ConfirmationDetails struct {
 	ExpiresAt int64 `json:"expires_at"`
 }
 
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey returns a context carrying the idempotency key of a
+// confirmed write, so executors can forward it for deduplication.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the key set by WithIdempotencyKey, or ""
+// if none was set.
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key
+}
+
 // ExecutorTool wraps a ToolExecutor to implement the Tool interface.
 // This allows Liminal tools to be used with the SDK's engine.
 type ExecutorTool struct {
@@ -113,6 +128,10 @@ func (t *ExecutorTool) Execute(ctx context.Context, params *ToolParams) (*ToolRe
 		RequestID: params.RequestID,
 	}
 
+	if params.IdempotencyKey != "" {
+		ctx = WithIdempotencyKey(ctx, params.IdempotencyKey)
+	}
+
 	var resp *ExecuteResponse
 	var err error
 
@@ -143,7 +162,19 @@ func (t *ExecutorTool) Execute(ctx context.Context, params *ToolParams) (*ToolRe
 	}, nil
 }
 
-// GetSummary returns a formatted summary.
+// GetSummary renders the summary template against the input.
+// See RenderSummary.
 func (t *ExecutorTool) GetSummary(input json.RawMessage) string {
-	return t.definition.SummaryTemplate
+	return RenderSummary(t.definition, input)
+}
+
+// DescribeAction returns structured details of the action, if the tool
+// has an ActionType.
+func (t *ExecutorTool) DescribeAction(ctx context.Context, params *ToolParams) (*ActionDetails, error) {
+	return describeAction(ctx, t.definition, params)
+}
+
+// ConfirmationPolicy returns the tool's confirmation policy, or nil.
+func (t *ExecutorTool) ConfirmationPolicy() ConfirmationPolicy {
+	return t.definition.ConfirmationPolicy
 }
@@ -0,0 +1,61 @@
+package core
+
+import "context"
+
+// ToolMiddleware wraps a ToolHandler to add behaviour such as timeouts,
+// retries or logging around tool execution.
+type ToolMiddleware func(next ToolHandler) ToolHandler
+
+// Chain wraps handler with the middlewares. The first middleware is the
+// outermost: it sees each call first and its result last.
+func Chain(handler ToolHandler, middlewares ...ToolMiddleware) ToolHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// WrapTool returns a Tool whose Execute runs through the middlewares.
+// All other methods are delegated to tool.
+func WrapTool(tool Tool, middlewares ...ToolMiddleware) Tool {
+	if len(middlewares) == 0 {
+		return tool
+	}
+	return &middlewareTool{
+		Tool:    tool,
+		handler: Chain(tool.Execute, middlewares...),
+	}
+}
+
+// middlewareTool is a Tool with middleware applied to Execute.
+type middlewareTool struct {
+	Tool
+	handler ToolHandler
+}
+
+// Execute runs the tool through its middleware chain.
+func (t *middlewareTool) Execute(ctx context.Context, params *ToolParams) (*ToolResult, error) {
+	return t.handler(ctx, params)
+}
+
+// DescribeAction delegates to the underlying tool if it describes its
+// actions, so wrapping a tool keeps its confirmation details.
+func (t *middlewareTool) DescribeAction(ctx context.Context, params *ToolParams) (*ActionDetails, error) {
+	if describer, ok := t.Tool.(ActionDescriber); ok {
+		return describer.DescribeAction(ctx, params)
+	}
+	return nil, nil
+}
+
+// ConfirmationPolicy delegates to the underlying tool if it has a policy.
+func (t *middlewareTool) ConfirmationPolicy() ConfirmationPolicy {
+	if provider, ok := t.Tool.(ConfirmationPolicyProvider); ok {
+		return provider.ConfirmationPolicy()
+	}
+	return nil
+}
+
+// Unwrap returns the underlying tool.
+func (t *middlewareTool) Unwrap() Tool {
+	return t.Tool
+}
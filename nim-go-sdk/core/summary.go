@@ -0,0 +1,273 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// SummaryFuncs are the helper functions available in SummaryTemplate:
+//
+//	{{money .amount .currency}}  -> "$1,250.00", "€50.005" or "12.50 LIL"
+//	{{address .recipient}}       -> the address in full, never shortened
+var SummaryFuncs = template.FuncMap{
+	"money":   FormatMoney,
+	"address": FormatAddress,
+}
+
+// RenderSummary renders a tool's SummaryTemplate against its input, so the
+// user confirms the exact action. Every property in the schema is available
+// to the template, with nil for properties missing from the input. If the
+// template is empty, fails to render or a required property is missing, a
+// generic summary is built from the schema instead.
+func RenderSummary(def ToolDefinition, input json.RawMessage) string {
+	values := decodeSummaryInput(input)
+
+	if def.SummaryTemplate != "" && values != nil && hasRequired(def.InputSchema, values) {
+		if summary, err := executeSummaryTemplate(def.SummaryTemplate, def.InputSchema, values); err == nil {
+			return summary
+		}
+	}
+	return GenericSummary(def, values)
+}
+
+// GenericSummary describes a tool call from its name and input values,
+// such as "Send money: recipient @alice, amount 50, currency USD".
+// Required properties are listed first, in schema order.
+func GenericSummary(def ToolDefinition, values map[string]interface{}) string {
+	title := humanizeToolName(def.ToolName)
+	if len(values) == 0 {
+		return title
+	}
+
+	parts := make([]string, 0, len(values))
+	for _, name := range summaryFieldOrder(def.InputSchema, values) {
+		value := values[name]
+		if value == nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", strings.ReplaceAll(name, "_", " "), summaryValue(value)))
+	}
+	if len(parts) == 0 {
+		return title
+	}
+	return title + ": " + strings.Join(parts, ", ")
+}
+
+// FormatMoney formats an amount with a currency, for example
+// FormatMoney("1250", "USD") is "$1,250.00". The amount is never rounded:
+// digits are grouped on its decimal string and the fraction is only padded
+// to two places, so "0.005" stays "0.005". Currencies without a known
+// symbol are written after the amount. Amounts that are not plain decimals
+// are returned as given.
+func FormatMoney(amount interface{}, currency ...interface{}) string {
+	code := ""
+	if len(currency) > 0 && currency[0] != nil {
+		code = strings.ToUpper(fmt.Sprint(currency[0]))
+	}
+
+	var formatted string
+	switch v := amount.(type) {
+	case float64:
+		formatted = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		formatted = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		formatted = strings.TrimSpace(fmt.Sprint(amount))
+	}
+	if isDecimal(formatted) {
+		formatted = groupThousands(padFraction(formatted))
+	}
+
+	switch code {
+	case "":
+		return formatted
+	case "USD":
+		return "$" + formatted
+	case "EUR":
+		return "€" + formatted
+	case "GBP":
+		return "£" + formatted
+	}
+	return formatted + " " + code
+}
+
+// FormatAddress returns an address or display tag in full. Summaries are
+// what the user confirms, and address poisoning relies on look-alike
+// addresses that share their first and last characters, so addresses are
+// never shortened.
+func FormatAddress(address interface{}) string {
+	return strings.TrimSpace(fmt.Sprint(address))
+}
+
+// summaryTemplates caches parsed templates by source.
+var summaryTemplates sync.Map // template source -> *template.Template
+
+func executeSummaryTemplate(source string, schema map[string]interface{}, values map[string]interface{}) (string, error) {
+	tmpl, ok := summaryTemplates.Load(source)
+	if !ok {
+		parsed, err := template.New("summary").Funcs(SummaryFuncs).Option("missingkey=error").Parse(source)
+		if err != nil {
+			return "", err
+		}
+		tmpl, _ = summaryTemplates.LoadOrStore(source, parsed)
+	}
+
+	// Optional properties are present as nil so templates can test them
+	// with {{if .note}}; unknown keys are still an error.
+	data := make(map[string]interface{}, len(values))
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		for name := range properties {
+			data[name] = nil
+		}
+	}
+	for name, value := range values {
+		data[name] = value
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.(*template.Template).Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// decodeSummaryInput parses the input as an object. Returns nil if it is not one.
+func decodeSummaryInput(input json.RawMessage) map[string]interface{} {
+	if len(bytes.TrimSpace(input)) == 0 {
+		return map[string]interface{}{}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil
+	}
+	return values
+}
+
+// hasRequired reports whether every required property has a value.
+func hasRequired(schema map[string]interface{}, values map[string]interface{}) bool {
+	for _, name := range requiredNames(schema) {
+		if values[name] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// requiredNames reads the schema's required list, which is a []string for
+// schemas built in Go and a []interface{} for schemas decoded from JSON.
+func requiredNames(schema map[string]interface{}) []string {
+	switch required := schema["required"].(type) {
+	case []string:
+		return required
+	case []interface{}:
+		names := make([]string, 0, len(required))
+		for _, r := range required {
+			if s, ok := r.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// summaryFieldOrder lists required properties first, then the rest sorted.
+func summaryFieldOrder(schema map[string]interface{}, values map[string]interface{}) []string {
+	order := make([]string, 0, len(values))
+	listed := make(map[string]bool)
+	for _, name := range requiredNames(schema) {
+		if _, ok := values[name]; ok && !listed[name] {
+			order = append(order, name)
+			listed[name] = true
+		}
+	}
+
+	rest := make([]string, 0, len(values))
+	for name := range values {
+		if !listed[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(order, rest...)
+}
+
+// summaryValue formats an input value for a generic summary.
+func summaryValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case bool:
+		s = strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		s = string(b)
+	}
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
+
+// humanizeToolName turns "send_money" into "Send money".
+func humanizeToolName(name string) string {
+	s := strings.ReplaceAll(name, "_", " ")
+	if s == "" {
+		return "Perform action"
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// isDecimal reports whether s is a plain decimal number such as "-1250.5".
+func isDecimal(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+	if intPart == "" || (hasFrac && frac == "") {
+		return false
+	}
+	for _, r := range intPart + frac {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// padFraction pads a decimal's fraction with zeros to at least two places.
+func padFraction(s string) string {
+	_, frac, _ := strings.Cut(s, ".")
+	if len(frac) >= 2 {
+		return s
+	}
+	if !strings.Contains(s, ".") {
+		s += "."
+	}
+	return s + strings.Repeat("0", 2-len(frac))
+}
+
+// groupThousands inserts commas into the integer part of a formatted number.
+func groupThousands(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+	for i := len(intPart) - 3; i > 0; i -= 3 {
+		intPart = intPart[:i] + "," + intPart[i:]
+	}
+	if hasFrac {
+		return sign + intPart + "." + frac
+	}
+	return sign + intPart
+}
@@ -31,12 +31,22 @@ type ToolParams struct {
 	// UserID is the authenticated user making the request.
 	UserID string
 
+	// ToolName is the name of the tool being executed, so middleware can
+	// identify it. Set by the engine.
+	ToolName string
+
 	// Input is the tool parameters as JSON.
 	Input json.RawMessage
 
 	// ConfirmationID is set for confirmed write operations.
 	ConfirmationID string
 
+	// IdempotencyKey is set for confirmed write operations. It identifies
+	// one approved action: retries of that action carry the same key, while
+	// another action with identical input gets a different one. Executors
+	// can use it to avoid performing the same action twice.
+	IdempotencyKey string
+
 	// RequestID for tracing/logging.
 	RequestID string
 }
@@ -67,11 +77,25 @@ type ToolDefinition struct {
 	// RequiresUserConfirmation indicates if user approval is needed.
 	RequiresUserConfirmation bool
 
-	// SummaryTemplate is a Go template for generating summaries.
+	// SummaryTemplate is a Go template for generating summaries, rendered
+	// against the tool input with the helpers in SummaryFuncs.
 	SummaryTemplate string
 
 	// InputSchema is the JSON Schema for parameters.
 	InputSchema map[string]interface{}
+
+	// ActionType is the kind of write the tool performs, such as
+	// ActionTypeSend. If set, confirmations carry ActionDetails built
+	// from the input's amount, currency and recipient.
+	ActionType string
+
+	// Preflight optionally completes the ActionDetails before the user
+	// confirms. Only used when ActionType is set.
+	Preflight PreflightFunc
+
+	// ConfirmationPolicy optionally decides per call whether confirmation
+	// is needed, overriding RequiresUserConfirmation.
+	ConfirmationPolicy ConfirmationPolicy
 }
 
 // BaseTool provides common tool functionality.
@@ -119,9 +143,21 @@ func (t *BaseTool) Execute(ctx context.Context, params *ToolParams) (*ToolResult
 	return t.handler(ctx, params)
 }
 
-// GetSummary returns a formatted summary using the template.
+// GetSummary renders the summary template against the input.
+// See RenderSummary.
 func (t *BaseTool) GetSummary(input json.RawMessage) string {
-	return t.definition.SummaryTemplate
+	return RenderSummary(t.definition, input)
+}
+
+// DescribeAction returns structured details of the action, if the tool
+// has an ActionType.
+func (t *BaseTool) DescribeAction(ctx context.Context, params *ToolParams) (*ActionDetails, error) {
+	return describeAction(ctx, t.definition, params)
+}
+
+// ConfirmationPolicy returns the tool's confirmation policy, or nil.
+func (t *BaseTool) ConfirmationPolicy() ConfirmationPolicy {
+	return t.definition.ConfirmationPolicy
 }
 
 // Definition returns the underlying ToolDefinition.
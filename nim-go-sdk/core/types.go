@@ -42,6 +42,14 @@ type ContentBlock struct {
 
 	// ToolResult contains tool execution result (for ToolResultBlock type).
 	ToolResult *ToolResultContent `json:"tool_result,omitempty"`
+
+	// Thinking contains Claude's extended thinking (for ThinkingBlock and
+	// RedactedThinkingBlock types).
+	Thinking *ThinkingContent `json:"thinking,omitempty"`
+
+	// Attachment contains an image or document sent by the user (for
+	// ImageBlock and DocumentBlock types).
+	Attachment *AttachmentContent `json:"attachment,omitempty"`
 }
 
 // ContentBlockType indicates the type of content block.
@@ -56,6 +64,19 @@ const (
 
 	// ToolResultBlockType contains the result of a tool execution.
 	ToolResultBlockType ContentBlockType = "tool_result"
+
+	// ThinkingBlockType contains Claude's extended thinking.
+	ThinkingBlockType ContentBlockType = "thinking"
+
+	// RedactedThinkingBlockType contains extended thinking that was
+	// encrypted for safety reasons.
+	RedactedThinkingBlockType ContentBlockType = "redacted_thinking"
+
+	// ImageBlockType contains an image attachment.
+	ImageBlockType ContentBlockType = "image"
+
+	// DocumentBlockType contains a document attachment, such as a PDF.
+	DocumentBlockType ContentBlockType = "document"
 )
 
 // ToolUseContent contains details about a tool invocation.
@@ -82,6 +103,20 @@ type ToolResultContent struct {
 	IsError bool `json:"is_error,omitempty"`
 }
 
+// ThinkingContent contains Claude's extended thinking. It must be sent back
+// unmodified with the tool results of the same turn, so the signature
+// and redacted data are kept.
+type ThinkingContent struct {
+	// Thinking is the thinking text. Empty for redacted thinking.
+	Thinking string `json:"thinking,omitempty"`
+
+	// Signature verifies that the thinking was produced by Claude.
+	Signature string `json:"signature,omitempty"`
+
+	// Data is the encrypted content of redacted thinking.
+	Data string `json:"data,omitempty"`
+}
+
 // NewUserMessage creates a user text message.
 func NewUserMessage(text string) Message {
 	return Message{Role: RoleUser, Content: text}
@@ -138,6 +173,25 @@ func NewToolResultBlock(toolUseID, content string, isError bool) ContentBlock {
 	}
 }
 
+// NewThinkingBlock creates a thinking content block.
+func NewThinkingBlock(thinking, signature string) ContentBlock {
+	return ContentBlock{
+		Type: ThinkingBlockType,
+		Thinking: &ThinkingContent{
+			Thinking:  thinking,
+			Signature: signature,
+		},
+	}
+}
+
+// NewRedactedThinkingBlock creates a redacted_thinking content block.
+func NewRedactedThinkingBlock(data string) ContentBlock {
+	return ContentBlock{
+		Type:     RedactedThinkingBlockType,
+		Thinking: &ThinkingContent{Data: data},
+	}
+}
+
 // GetText returns all text content concatenated.
 func (m *Message) GetText() string {
 	if m.Content != "" {
@@ -238,6 +292,16 @@ type UserLimits struct {
 
 	// SingleTransferMax is the maximum amount for a single transfer.
 	SingleTransferMax string `json:"single_transfer_max"`
+
+	// AutoApproveDepositMax is the largest savings deposit the user allows
+	// without confirmation. Empty always confirms. See
+	// engine.AutoApproveDeposits.
+	AutoApproveDepositMax string `json:"auto_approve_deposit_max,omitempty"`
+
+	// AutoApproveDepositWindowMax is the most the user allows to be
+	// deposited without confirmation in total per
+	// engine.AutoApproveWindow. Empty allows AutoApproveDepositMax.
+	AutoApproveDepositWindowMax string `json:"auto_approve_deposit_window_max,omitempty"`
 }
 
 // DefaultUserLimits returns sensible default user limits.
@@ -260,9 +324,15 @@ type ExecutionLimits struct {
 	// Timeout is the maximum execution time.
 	Timeout time.Duration
 
-	// MaxToolCalls is the maximum total tool calls per execution.
+	// MaxToolCalls is the maximum total tool calls per request, including
+	// calls made before a confirmation pause. Zero means unlimited.
 	MaxToolCalls int
 
+	// ToolQuotas optionally caps calls per tool name per request
+	// (e.g., {"search_users": 3}). Tools without an entry are only
+	// bound by MaxToolCalls.
+	ToolQuotas map[string]int
+
 	// CanConfirm indicates whether this execution can request user confirmation.
 	CanConfirm bool
 }
@@ -347,7 +417,8 @@ type PendingAction struct {
 	ID string `json:"id"`
 
 	// IdempotencyKey is a hash for deduplicating similar confirmations.
-	// Generated from userID, tool, input, and time bucket.
+	// Generated from userID, tool, input, and time bucket. It is not sent
+	// to executors, which receive ID as the key of the action.
 	IdempotencyKey string `json:"idempotency_key"`
 
 	// SessionID identifies which session created this confirmation.
@@ -365,9 +436,35 @@ type PendingAction struct {
 	// Summary is a human-readable description of the action.
 	Summary string `json:"summary"`
 
+	// Details describes the action in structured form, for tools that
+	// implement ActionDescriber. Nil otherwise.
+	Details *ActionDetails `json:"details,omitempty"`
+
+	// ConfirmationLevel is the approval required: ConfirmationRequired or
+	// ConfirmationStepUp. Empty is treated as ConfirmationRequired.
+	ConfirmationLevel ConfirmationLevel `json:"confirmation_level,omitempty"`
+
+	// ConfirmationReason is why the confirmation policy asked for approval,
+	// if it gave a reason.
+	ConfirmationReason string `json:"confirmation_reason,omitempty"`
+
 	// BlockID is Claude's tool_use block ID for session reconstruction.
 	BlockID string `json:"block_id"`
 
+	// RequestID is the request that proposed this action. Audit entries for
+	// the action's confirmation lifecycle are recorded under it.
+	RequestID string `json:"request_id,omitempty"`
+
+	// AgentName is the agent that proposed this action.
+	AgentName string `json:"agent_name,omitempty"`
+
+	// PlanID groups writes proposed together in one turn into a plan that
+	// can be approved with a single confirmation. Empty for single actions.
+	PlanID string `json:"plan_id,omitempty"`
+
+	// PlanStep is the 1-based position of this action within its plan.
+	PlanStep int `json:"plan_step,omitempty"`
+
 	// CreatedAt is when the action was created (unix timestamp).
 	CreatedAt int64 `json:"created_at"`
 
@@ -3,6 +3,9 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"sync"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
 )
 
 // AuditLogger logs tool executions for compliance and debugging.
@@ -13,11 +16,53 @@ type AuditLogger interface {
 	Log(ctx context.Context, entry *AuditEntry) error
 }
 
+// Audit event types recorded in AuditEntry.EventType.
+const (
+	// AuditEventToolExecution is a tool run without confirmation.
+	AuditEventToolExecution = "tool_execution"
+
+	// AuditEventModelTurn is a single Claude API call.
+	AuditEventModelTurn = "model_turn"
+
+	// AuditEventRunInterrupted is a run cancelled before it finished, such
+	// as one stopped by the user. Detail holds the cancellation cause.
+	AuditEventRunInterrupted = "run_interrupted"
+
+	// AuditEventConfirmationCreated is a write proposed for confirmation.
+	AuditEventConfirmationCreated = "confirmation_created"
+
+	// AuditEventConfirmationWaived is a write executed without asking the
+	// user because a confirmation policy waived it.
+	AuditEventConfirmationWaived = "confirmation_waived"
+
+	// AuditEventConfirmationConfirmed is a write approved by the user.
+	AuditEventConfirmationConfirmed = "confirmation_confirmed"
+
+	// AuditEventConfirmationCancelled is a write rejected or abandoned.
+	AuditEventConfirmationCancelled = "confirmation_cancelled"
+
+	// AuditEventConfirmationExpired is a write that expired before the user
+	// approved it, found when the user tried to confirm it or when expired
+	// actions were cleaned up.
+	AuditEventConfirmationExpired = "confirmation_expired"
+
+	// AuditEventConfirmationExecuted is a confirmed write that was run.
+	AuditEventConfirmationExecuted = "confirmation_executed"
+
+	// AuditEventLogRecovered records that FileAuditLogger removed an
+	// incomplete entry at the end of the log on startup, for example one
+	// cut short by a crash. Detail says how much was removed.
+	AuditEventLogRecovered = "log_recovered"
+)
+
 // AuditEntry represents a single audit log entry.
 type AuditEntry struct {
 	// ID is the unique identifier for this audit entry.
 	ID string `json:"id"`
 
+	// EventType is one of the AuditEvent constants.
+	EventType string `json:"event_type"`
+
 	// UserID is the user who initiated the action.
 	UserID string `json:"user_id"`
 
@@ -25,28 +70,45 @@ type AuditEntry struct {
 	SessionID string `json:"session_id"`
 
 	// RequestID is the unique request identifier for tracing.
+	// Every entry produced by one agent run shares a RequestID.
 	RequestID string `json:"request_id"`
 
-	// ParentID links sub-agent entries to their parent.
-	// Nil for top-level agent executions.
+	// ParentID is the RequestID of the run that started this one, linking
+	// sub-agent entries to their parent. Nil for top-level agent executions.
 	ParentID *string `json:"parent_id,omitempty"`
 
 	// AgentName identifies which agent executed the tool.
 	AgentName string `json:"agent_name"`
 
 	// ToolName is the name of the tool that was executed.
-	ToolName string `json:"tool_name"`
+	ToolName string `json:"tool_name,omitempty"`
 
 	// ToolInput contains the tool parameters as JSON.
-	ToolInput json.RawMessage `json:"tool_input"`
+	ToolInput json.RawMessage `json:"tool_input,omitempty"`
 
 	// ToolOutput contains the tool result as JSON.
 	ToolOutput json.RawMessage `json:"tool_output,omitempty"`
 
+	// ActionID is the pending action for confirmation events.
+	ActionID string `json:"action_id,omitempty"`
+
+	// Detail explains a confirmation event, such as why it was cancelled.
+	Detail string `json:"detail,omitempty"`
+
+	// Model is the Claude model for model turns.
+	Model string `json:"model,omitempty"`
+
+	// StopReason is why Claude stopped generating, for model turns.
+	StopReason string `json:"stop_reason,omitempty"`
+
+	// TokenUsage is the token usage of a model turn.
+	TokenUsage *core.TokenUsage `json:"token_usage,omitempty"`
+
 	// Error contains any error message if the tool failed.
 	Error *string `json:"error,omitempty"`
 
 	// DurationMs is the execution time in milliseconds.
+	// For model turns this is the API latency.
 	DurationMs int64 `json:"duration_ms"`
 
 	// IsWriteOp indicates whether this was a write operation.
@@ -54,6 +116,15 @@ type AuditEntry struct {
 
 	// Timestamp is when the tool execution started (Unix timestamp).
 	Timestamp int64 `json:"timestamp"`
+
+	// PrevHash is the Hash of the previous entry in a hash-chained log.
+	// Set by FileAuditLogger; empty for the first entry.
+	PrevHash string `json:"prev_hash,omitempty"`
+
+	// Hash is the SHA-256 of this entry (with Hash empty), covering PrevHash,
+	// so any modification or removal of an entry breaks the chain.
+	// Set by FileAuditLogger.
+	Hash string `json:"hash,omitempty"`
 }
 
 // NoOpAuditLogger is an audit logger that discards all entries.
@@ -66,8 +137,9 @@ func (n *NoOpAuditLogger) Log(ctx context.Context, entry *AuditEntry) error {
 }
 
 // MemoryAuditLogger stores audit entries in memory.
-// Useful for testing and debugging.
+// Useful for testing and debugging. Safe for concurrent use.
 type MemoryAuditLogger struct {
+	mu      sync.RWMutex
 	entries []*AuditEntry
 }
 
@@ -80,16 +152,144 @@ func NewMemoryAuditLogger() *MemoryAuditLogger {
 
 // Log stores the audit entry in memory.
 func (m *MemoryAuditLogger) Log(ctx context.Context, entry *AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.entries = append(m.entries, entry)
 	return nil
 }
 
 // Entries returns all stored audit entries.
 func (m *MemoryAuditLogger) Entries() []*AuditEntry {
-	return m.entries
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := make([]*AuditEntry, len(m.entries))
+	copy(entries, m.entries)
+	return entries
+}
+
+// Query returns the stored entries matching the query.
+func (m *MemoryAuditLogger) Query(ctx context.Context, q AuditQuery) ([]*AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []*AuditEntry
+	for _, entry := range m.entries {
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+		if q.Matches(entry) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
 }
 
 // Clear removes all stored entries.
 func (m *MemoryAuditLogger) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.entries = make([]*AuditEntry, 0)
 }
+
+// AuditQuery filters audit entries. Zero-valued fields match everything.
+type AuditQuery struct {
+	// UserID matches entries for this user.
+	UserID string
+
+	// SessionID matches entries for this session.
+	SessionID string
+
+	// RequestID matches entries for this request. Use BuildAuditTree to
+	// include its sub-agent requests.
+	RequestID string
+
+	// EventType matches entries of this event type.
+	EventType string
+
+	// ToolName matches entries for this tool.
+	ToolName string
+
+	// WriteOnly matches only write operations.
+	WriteOnly bool
+
+	// Since matches entries with Timestamp at or after this Unix timestamp.
+	Since int64
+
+	// Until matches entries with Timestamp before this Unix timestamp.
+	Until int64
+
+	// Limit caps the number of entries returned.
+	Limit int
+}
+
+// Matches reports whether the entry satisfies the query.
+func (q AuditQuery) Matches(entry *AuditEntry) bool {
+	if q.UserID != "" && entry.UserID != q.UserID {
+		return false
+	}
+	if q.SessionID != "" && entry.SessionID != q.SessionID {
+		return false
+	}
+	if q.RequestID != "" && entry.RequestID != q.RequestID {
+		return false
+	}
+	if q.EventType != "" && entry.EventType != q.EventType {
+		return false
+	}
+	if q.ToolName != "" && entry.ToolName != q.ToolName {
+		return false
+	}
+	if q.WriteOnly && !entry.IsWriteOp {
+		return false
+	}
+	if q.Since != 0 && entry.Timestamp < q.Since {
+		return false
+	}
+	if q.Until != 0 && entry.Timestamp >= q.Until {
+		return false
+	}
+	return true
+}
+
+// AuditNode is one agent run in an audit tree: its entries and the
+// sub-agent runs it started.
+type AuditNode struct {
+	// RequestID identifies the run.
+	RequestID string
+
+	// Entries are the run's audit entries in the order given.
+	Entries []*AuditEntry
+
+	// Children are the sub-agent runs started by this run.
+	Children []*AuditNode
+}
+
+// BuildAuditTree groups entries by RequestID and links runs to their parent
+// through ParentID. Runs whose parent is not among the entries are returned
+// as roots, in order of their first entry.
+func BuildAuditTree(entries []*AuditEntry) []*AuditNode {
+	nodes := make(map[string]*AuditNode)
+	parents := make(map[string]string)
+	var order []string
+	for _, entry := range entries {
+		node, ok := nodes[entry.RequestID]
+		if !ok {
+			node = &AuditNode{RequestID: entry.RequestID}
+			nodes[entry.RequestID] = node
+			order = append(order, entry.RequestID)
+		}
+		node.Entries = append(node.Entries, entry)
+		if entry.ParentID != nil && *entry.ParentID != entry.RequestID {
+			parents[entry.RequestID] = *entry.ParentID
+		}
+	}
+
+	var roots []*AuditNode
+	for _, id := range order {
+		if parent, ok := nodes[parents[id]]; ok {
+			parent.Children = append(parent.Children, nodes[id])
+			continue
+		}
+		roots = append(roots, nodes[id])
+	}
+	return roots
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// auditScope holds the identifiers shared by every audit entry of a run.
+type auditScope struct {
+	userID    string
+	sessionID string
+	requestID string
+	parentID  *string
+	agentName string
+}
+
+// newAuditScope derives the audit identifiers for a run. The request ID comes
+// from the context when set, so entries can be correlated with the caller;
+// otherwise the engine session ID is used.
+func newAuditScope(input *Input, session *Session, agentName string) auditScope {
+	scope := auditScope{
+		userID:    session.UserID,
+		sessionID: session.ID,
+		requestID: session.ID,
+		agentName: agentName,
+	}
+	if input.Context != nil {
+		if input.Context.SessionID != "" {
+			scope.sessionID = input.Context.SessionID
+		}
+		if input.Context.RequestID != "" {
+			scope.requestID = input.Context.RequestID
+		}
+		scope.parentID = input.Context.AuditParentID
+	}
+	return scope
+}
+
+// logAudit fills in the scope's identifiers and records the entry, if an
+// audit logger is configured.
+func (e *Engine) logAudit(ctx context.Context, scope auditScope, entry *AuditEntry) {
+	if e.audit == nil {
+		return
+	}
+
+	entry.ID = uuid.New().String()
+	entry.UserID = scope.userID
+	entry.SessionID = scope.sessionID
+	entry.RequestID = scope.requestID
+	entry.ParentID = scope.parentID
+	entry.AgentName = scope.agentName
+	if entry.Timestamp == 0 {
+		entry.Timestamp = time.Now().Unix()
+	}
+
+	// Record entries even when the run was cancelled, so the audit log
+	// shows how far it got
+	ctx = context.WithoutCancel(ctx)
+	if err := e.audit.Log(ctx, entry); err != nil {
+		if e.auditError != nil {
+			e.auditError(ctx, entry, err)
+			return
+		}
+		log.Printf("Failed to record %s audit entry %s for request %s: %v", entry.EventType, entry.ID, entry.RequestID, err)
+	}
+}
+
+// auditModelTurn records a single Claude API call.
+func (e *Engine) auditModelTurn(ctx context.Context, scope auditScope, turn *TurnInfo) {
+	entry := &AuditEntry{
+		EventType:  AuditEventModelTurn,
+		Model:      turn.Model,
+		StopReason: turn.StopReason,
+		DurationMs: turn.Latency.Milliseconds(),
+		Timestamp:  turn.Start.Unix(),
+	}
+	if turn.Error != nil {
+		errStr := turn.Error.Error()
+		entry.Error = &errStr
+	} else {
+		usage := turn.TokensUsed
+		entry.TokenUsage = &usage
+	}
+	e.logAudit(ctx, scope, entry)
+}
+
+// actionAuditScope returns the scope of the request that proposed an action.
+func actionAuditScope(action *core.PendingAction) auditScope {
+	return auditScope{
+		userID:    action.UserID,
+		sessionID: action.SessionID,
+		requestID: action.RequestID,
+		agentName: action.AgentName,
+	}
+}
+
+// actionAuditEntry returns an entry for a confirmation lifecycle event.
+func actionAuditEntry(event string, action *core.PendingAction) *AuditEntry {
+	return &AuditEntry{
+		EventType: event,
+		ActionID:  action.ID,
+		ToolName:  action.Tool,
+		ToolInput: action.Input,
+		IsWriteOp: true,
+	}
+}
+
+// confirmationDetail describes the approval level and the policy's reason
+// for an audit entry, such as "step_up: send above 1000".
+func confirmationDetail(level core.ConfirmationLevel, reason string) string {
+	if level == "" {
+		level = core.ConfirmationRequired
+	}
+	if reason == "" {
+		return string(level)
+	}
+	return string(level) + ": " + reason
+}
+
+// AuditConfirmation records a confirmation lifecycle event for an action,
+// such as AuditEventConfirmationConfirmed, AuditEventConfirmationCancelled or
+// AuditEventConfirmationExpired. The entry is linked to the request that
+// proposed the action. Creation and execution are audited by the engine
+// itself. detail explains the event and may be empty.
+func (e *Engine) AuditConfirmation(ctx context.Context, event string, action *core.PendingAction, detail string) {
+	entry := actionAuditEntry(event, action)
+	entry.Detail = detail
+	e.logAudit(ctx, actionAuditScope(action), entry)
+}
+
+// auditActionExecuted records the execution of a confirmed action.
+func (e *Engine) auditActionExecuted(ctx context.Context, action *core.PendingAction, start time.Time, result *core.ToolResult, err error) {
+	entry := actionAuditEntry(AuditEventConfirmationExecuted, action)
+	entry.DurationMs = time.Since(start).Milliseconds()
+	entry.Timestamp = start.Unix()
+	if err != nil {
+		errStr := err.Error()
+		entry.Error = &errStr
+	} else if result != nil {
+		if result.Data != nil {
+			entry.ToolOutput, _ = json.Marshal(result.Data)
+		}
+		if !result.Success {
+			errStr := result.Error
+			entry.Error = &errStr
+		}
+	}
+	e.logAudit(ctx, actionAuditScope(action), entry)
+}
@@ -0,0 +1,464 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit files are named audit-YYYYMMDD-NNNN.jsonl so that lexical order is
+// chronological order. A new file is started each UTC day and whenever the
+// current file would exceed FileAuditConfig.MaxSize.
+const (
+	auditFilePrefix = "audit-"
+	auditFileSuffix = ".jsonl"
+	auditDateLayout = "20060102"
+)
+
+// FileAuditConfig configures a FileAuditLogger.
+type FileAuditConfig struct {
+	// Dir is the directory audit files are written to. Created if missing.
+	Dir string
+
+	// MaxSize is the maximum size of a single file in bytes.
+	// Zero disables size-based rotation.
+	MaxSize int64
+
+	// Sync flushes every entry to stable storage before Log returns.
+	Sync bool
+}
+
+// FileAuditLogger is an append-only JSON Lines audit sink. Entries are
+// hash-chained: each carries the hash of the previous entry, so modifying,
+// reordering or removing entries is detected by VerifyAuditLog.
+// Safe for concurrent use.
+type FileAuditLogger struct {
+	cfg FileAuditConfig
+
+	mu       sync.Mutex
+	file     *os.File
+	date     string
+	seq      int
+	size     int64
+	lastHash string
+}
+
+// NewFileAuditLogger opens (or creates) an audit log in cfg.Dir.
+// The hash chain continues from the last entry already on disk. An
+// incomplete last entry, left by a crash mid-write, is removed and the
+// removal is recorded as an AuditEventLogRecovered entry.
+func NewFileAuditLogger(cfg FileAuditConfig) (*FileAuditLogger, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("audit directory is required")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	f := &FileAuditLogger{cfg: cfg}
+
+	files, err := auditFiles(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var removed int64
+	if len(files) > 0 {
+		latest := files[len(files)-1]
+		var last *AuditEntry
+		last, removed, err = recoverAuditFile(latest)
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			f.lastHash = last.Hash
+		}
+		if f.date, f.seq, err = parseAuditFileName(filepath.Base(latest)); err != nil {
+			return nil, err
+		}
+		if removed > 0 {
+			err = f.Log(context.Background(), &AuditEntry{
+				ID:        uuid.New().String(),
+				EventType: AuditEventLogRecovered,
+				Detail:    fmt.Sprintf("removed %d bytes of an incomplete entry at the end of %s", removed, filepath.Base(latest)),
+				Timestamp: time.Now().Unix(),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// Log appends the entry to the current file. It sets entry.PrevHash and
+// entry.Hash before writing.
+func (f *FileAuditLogger) Log(ctx context.Context, entry *AuditEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry.PrevHash = f.lastHash
+	hash, err := hashAuditEntry(entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	if err := f.rotateUnlocked(time.Now().UTC(), int64(len(line))); err != nil {
+		return err
+	}
+	if _, err := f.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	if f.cfg.Sync {
+		if err := f.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync audit file: %w", err)
+		}
+	}
+
+	f.size += int64(len(line))
+	f.lastHash = hash
+	return nil
+}
+
+// Query returns entries from all audit files matching the query, oldest first.
+// Files are read without blocking Log; entries logged while the query runs
+// are not included.
+func (f *FileAuditLogger) Query(ctx context.Context, q AuditQuery) ([]*AuditEntry, error) {
+	// Snapshot the files and how much of the current one is complete, so
+	// a line being appended is never read half-written
+	f.mu.Lock()
+	files, err := auditFiles(f.cfg.Dir)
+	current, currentSize := "", int64(0)
+	if f.file != nil {
+		current, currentSize = f.file.Name(), f.size
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*AuditEntry
+	for _, path := range files {
+		limit := int64(-1)
+		if path == current {
+			limit = currentSize
+		}
+		err := readAuditFileLimit(path, limit, func(entry *AuditEntry, _ int) error {
+			if q.Matches(entry) {
+				result = append(result, entry)
+			}
+			if q.Limit > 0 && len(result) >= q.Limit {
+				return errStopReading
+			}
+			return nil
+		})
+		if errors.Is(err, errStopReading) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Close closes the current audit file.
+func (f *FileAuditLogger) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closeUnlocked()
+}
+
+// rotateUnlocked makes sure the open file is for today and has room for n
+// more bytes, starting a new file if not. Caller must hold the lock.
+func (f *FileAuditLogger) rotateUnlocked(now time.Time, n int64) error {
+	if date := now.Format(auditDateLayout); f.date != date {
+		if err := f.closeUnlocked(); err != nil {
+			return err
+		}
+		f.date = date
+		f.seq = 0
+	}
+	if f.file == nil {
+		if err := f.openUnlocked(); err != nil {
+			return err
+		}
+	}
+
+	if f.cfg.MaxSize > 0 && f.size > 0 && f.size+n > f.cfg.MaxSize {
+		if err := f.closeUnlocked(); err != nil {
+			return err
+		}
+		f.seq++
+		return f.openUnlocked()
+	}
+	return nil
+}
+
+// closeUnlocked closes the current file, if any. Caller must hold the lock.
+func (f *FileAuditLogger) closeUnlocked() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close audit file: %w", err)
+	}
+	return nil
+}
+
+// openUnlocked opens the file for the current date and sequence number.
+// Caller must hold the lock.
+func (f *FileAuditLogger) openUnlocked() error {
+	path := filepath.Join(f.cfg.Dir, auditFileName(f.date, f.seq))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// AuditChainError describes where an audit log's hash chain is broken.
+type AuditChainError struct {
+	// File is the audit file containing the broken entry.
+	File string
+
+	// Line is the 1-based line number of the broken entry.
+	Line int
+
+	// EntryID is the ID of the broken entry, if it could be parsed.
+	EntryID string
+
+	// Reason describes the problem.
+	Reason string
+}
+
+func (e *AuditChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at %s:%d (entry %s): %s", e.File, e.Line, e.EntryID, e.Reason)
+}
+
+// VerifyAuditLog walks every audit file in dir in order and checks that each
+// entry's hash is correct and links to the previous entry. It returns the
+// number of verified entries, and an *AuditChainError for the first entry
+// that fails verification.
+func VerifyAuditLog(dir string) (int, error) {
+	files, err := auditFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	prevHash := ""
+	for _, path := range files {
+		err := readAuditFile(path, func(entry *AuditEntry, line int) error {
+			chainErr := &AuditChainError{File: path, Line: line, EntryID: entry.ID}
+			if entry.PrevHash != prevHash {
+				chainErr.Reason = "previous hash does not match; an entry was removed, reordered or modified"
+				return chainErr
+			}
+			hash, err := hashAuditEntry(entry)
+			if err != nil {
+				return err
+			}
+			if hash != entry.Hash {
+				chainErr.Reason = "entry hash does not match its contents"
+				return chainErr
+			}
+			prevHash = entry.Hash
+			count++
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// hashAuditEntry returns the SHA-256 of the entry's JSON with Hash empty.
+func hashAuditEntry(entry *AuditEntry) (string, error) {
+	unhashed := *entry
+	unhashed.Hash = ""
+	data, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// errStopReading stops readAuditFile early without reporting an error.
+var errStopReading = errors.New("stop reading")
+
+// readAuditFile calls fn for every entry in the file, in order.
+func readAuditFile(path string, fn func(entry *AuditEntry, line int) error) error {
+	return readAuditFileLimit(path, -1, fn)
+}
+
+// readAuditFileLimit calls fn for every entry in the first limit bytes of
+// the file, in order. A negative limit reads the whole file.
+func readAuditFileLimit(path string, limit int64, fn func(entry *AuditEntry, line int) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if limit >= 0 {
+		r = io.LimitReader(file, limit)
+	}
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 {
+			var entry AuditEntry
+			if jsonErr := json.Unmarshal(raw, &entry); jsonErr != nil {
+				return &AuditChainError{File: path, Line: line, Reason: fmt.Sprintf("invalid JSON: %v", jsonErr)}
+			}
+			if fnErr := fn(&entry, line); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audit file: %w", err)
+		}
+	}
+}
+
+// recoverAuditFile returns the last entry in the file, or nil if it is
+// empty. If the file ends with an incomplete line that is not valid JSON,
+// it is truncated to the last complete entry and the number of bytes
+// removed is returned. Invalid lines before the end are an error.
+func recoverAuditFile(path string) (*AuditEntry, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read audit file: %w", err)
+	}
+
+	var last *AuditEntry
+	var complete int64 // bytes up to and including the last complete line
+	for line := 1; complete < int64(len(data)); line++ {
+		raw, _, hasNewline := bytes.Cut(data[complete:], []byte{'\n'})
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 {
+			var entry AuditEntry
+			if err := json.Unmarshal(trimmed, &entry); err != nil {
+				if !hasNewline {
+					break // Every entry is written with its newline; this one was cut short
+				}
+				return nil, 0, &AuditChainError{File: path, Line: line, Reason: fmt.Sprintf("invalid JSON: %v", err)}
+			}
+			last = &entry
+		}
+		if !hasNewline {
+			// A whole entry missing only its newline
+			if err := appendToFile(path, []byte{'\n'}); err != nil {
+				return nil, 0, err
+			}
+			complete = int64(len(data))
+			break
+		}
+		complete += int64(len(raw)) + 1
+	}
+
+	removed := int64(len(data)) - complete
+	if removed > 0 {
+		if err := os.Truncate(path, complete); err != nil {
+			return nil, 0, fmt.Errorf("failed to truncate incomplete audit entry: %w", err)
+		}
+	}
+	return last, removed, nil
+}
+
+// appendToFile appends data to the file at path.
+func appendToFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit file: %w", err)
+	}
+	return nil
+}
+
+// auditFiles returns the audit files in dir in chronological order.
+func auditFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit directory: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if _, _, err := parseAuditFileName(e.Name()); err == nil {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func auditFileName(date string, seq int) string {
+	return fmt.Sprintf("%s%s-%04d%s", auditFilePrefix, date, seq, auditFileSuffix)
+}
+
+func parseAuditFileName(name string) (date string, seq int, err error) {
+	if !strings.HasPrefix(name, auditFilePrefix) || !strings.HasSuffix(name, auditFileSuffix) {
+		return "", 0, fmt.Errorf("not an audit file: %s", name)
+	}
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(name, auditFilePrefix), auditFileSuffix)
+	date, seqStr, ok := strings.Cut(trimmed, "-")
+	if !ok {
+		return "", 0, fmt.Errorf("not an audit file: %s", name)
+	}
+	if _, err := time.Parse(auditDateLayout, date); err != nil {
+		return "", 0, fmt.Errorf("not an audit file: %s", name)
+	}
+	if seq, err = strconv.Atoi(seqStr); err != nil {
+		return "", 0, fmt.Errorf("not an audit file: %s", name)
+	}
+	return date, seq, nil
+}
+
+// Verify FileAuditLogger implements AuditLogger.
+var _ AuditLogger = (*FileAuditLogger)(nil)
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Prompt caching places up to three cache_control breakpoints on each request:
+// the system prompt, the last tool definition and the last block of the
+// conversation. Claude caches the prefix up to each breakpoint, so a
+// multi-turn agent run only pays full price for the newest messages.
+
+// cacheSystemPrompt marks the end of the system prompt as a cache breakpoint.
+func cacheSystemPrompt(system []anthropic.TextBlockParam) {
+	if len(system) == 0 {
+		return
+	}
+	system[len(system)-1].CacheControl = anthropic.NewCacheControlEphemeralParam()
+}
+
+// cacheTools marks the last tool definition as a cache breakpoint.
+// Tools must be in a stable order for the cached prefix to be reused.
+func cacheTools(tools []anthropic.ToolUnionParam) {
+	if len(tools) == 0 {
+		return
+	}
+	if cc := tools[len(tools)-1].GetCacheControl(); cc != nil {
+		*cc = anthropic.NewCacheControlEphemeralParam()
+	}
+}
+
+// cacheConversation marks the last cacheable block of the conversation as a
+// cache breakpoint. Messages are shared with the session, so the returned
+// function must be called once the request has been sent to remove the
+// breakpoint again; otherwise breakpoints would accumulate across turns.
+func cacheConversation(messages []anthropic.MessageParam) (restore func()) {
+	if len(messages) == 0 {
+		return func() {}
+	}
+
+	content := messages[len(messages)-1].Content
+	for i := len(content) - 1; i >= 0; i-- {
+		cc := content[i].GetCacheControl()
+		if cc == nil {
+			continue
+		}
+		previous := *cc
+		*cc = anthropic.NewCacheControlEphemeralParam()
+		return func() { *cc = previous }
+	}
+	return func() {}
+}
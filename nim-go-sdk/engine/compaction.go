@@ -0,0 +1,327 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// SummaryMessagePrefix starts the synthetic user message that replaces
+// compacted turns, so Claude can tell it apart from what the user said.
+const SummaryMessagePrefix = "[Summary of the earlier conversation]\n"
+
+// CompactionPrompt is the system prompt used to summarise older turns.
+const CompactionPrompt = `You summarise the earlier part of a conversation between a user and a financial assistant so the assistant can continue it without the full transcript.
+Keep every fact the assistant may need later: names and display tags, amounts and currencies, balances, dates, decisions the user made, actions that were performed, confirmed or cancelled, and open questions.
+Drop greetings and small talk. Write plain prose or short bullet points, at most 300 words. Return ONLY the summary.`
+
+// CompactionConfig configures a ContextManager.
+type CompactionConfig struct {
+	// MaxHistoryTokens is the estimated history size above which older
+	// turns are summarised.
+	MaxHistoryTokens int
+
+	// KeepRecentTokens is roughly how much of the most recent history is
+	// kept verbatim. At least the latest turn is always kept.
+	KeepRecentTokens int
+
+	// Model summarises the older turns. A small, fast model keeps
+	// compaction cheap.
+	Model anthropic.Model
+
+	// MaxSummaryTokens is the maximum length of the summary.
+	MaxSummaryTokens int64
+}
+
+// DefaultCompactionConfig returns sensible defaults for history compaction.
+func DefaultCompactionConfig() *CompactionConfig {
+	return &CompactionConfig{
+		MaxHistoryTokens: 100_000,
+		KeepRecentTokens: 20_000,
+		Model:            anthropic.ModelClaudeHaiku4_5,
+		MaxSummaryTokens: 1024,
+	}
+}
+
+// Compaction is the result of compacting a conversation history.
+type Compaction struct {
+	// Summary summarises the replaced messages.
+	Summary string
+
+	// History is the compacted history: the summary message followed by
+	// the kept messages.
+	History []core.Message
+
+	// Replaced is the number of leading messages the summary replaces.
+	Replaced int
+
+	// KeptTurns is the number of user turns kept verbatim after the summary.
+	KeptTurns int
+
+	// TokensBefore and TokensAfter are the estimated history sizes.
+	TokensBefore int
+	TokensAfter  int
+}
+
+// ContextManager keeps conversation history within a token budget by
+// summarising older turns into a single synthetic message. It never
+// separates a tool_use block from its tool_result.
+type ContextManager struct {
+	client *anthropic.Client
+	cfg    CompactionConfig
+}
+
+// NewContextManager creates a context manager. Zero fields in cfg take
+// their default values.
+func NewContextManager(client *anthropic.Client, cfg *CompactionConfig) *ContextManager {
+	defaults := DefaultCompactionConfig()
+	c := *defaults
+	if cfg != nil {
+		c = *cfg
+		if c.MaxHistoryTokens <= 0 {
+			c.MaxHistoryTokens = defaults.MaxHistoryTokens
+		}
+		if c.KeepRecentTokens <= 0 {
+			c.KeepRecentTokens = defaults.KeepRecentTokens
+		}
+		if c.Model == "" {
+			c.Model = defaults.Model
+		}
+		if c.MaxSummaryTokens <= 0 {
+			c.MaxSummaryTokens = defaults.MaxSummaryTokens
+		}
+	}
+	return &ContextManager{client: client, cfg: c}
+}
+
+// Compact summarises older turns if the history exceeds MaxHistoryTokens.
+// Returns nil if the history is within budget or has no safe place to cut.
+func (m *ContextManager) Compact(ctx context.Context, history []core.Message) (*Compaction, error) {
+	sizes := make([]int, len(history))
+	total := 0
+	for i := range history {
+		sizes[i] = EstimateHistoryTokens(history[i : i+1])
+		total += sizes[i]
+	}
+	if total <= m.cfg.MaxHistoryTokens {
+		return nil, nil
+	}
+
+	cut := compactionCut(history, sizes, m.cfg.KeepRecentTokens)
+	if cut <= 0 {
+		return nil, nil
+	}
+
+	summary, err := m.summarize(ctx, history[:cut])
+	if err != nil {
+		return nil, err
+	}
+
+	compacted := make([]core.Message, 0, len(history)-cut+1)
+	compacted = append(compacted, NewSummaryMessage(summary))
+	compacted = append(compacted, history[cut:]...)
+
+	keptTurns := 0
+	for _, msg := range history[cut:] {
+		if isTurnStart(msg) {
+			keptTurns++
+		}
+	}
+
+	return &Compaction{
+		Summary:      summary,
+		History:      compacted,
+		Replaced:     cut,
+		KeptTurns:    keptTurns,
+		TokensBefore: total,
+		TokensAfter:  EstimateHistoryTokens(compacted),
+	}, nil
+}
+
+// NewSummaryMessage creates the synthetic message that stands in for
+// compacted turns.
+func NewSummaryMessage(summary string) core.Message {
+	return core.NewUserMessage(SummaryMessagePrefix + summary)
+}
+
+// IsSummaryMessage reports whether msg was created by NewSummaryMessage.
+func IsSummaryMessage(msg core.Message) bool {
+	return msg.Role == core.RoleUser && strings.HasPrefix(msg.Content, SummaryMessagePrefix)
+}
+
+// EstimateTokens estimates the token size of messages as sent to the API,
+// at roughly four bytes of JSON per token.
+func EstimateTokens(messages []anthropic.MessageParam) int {
+	b, err := json.Marshal(messages)
+	if err != nil {
+		return 0
+	}
+	return (len(b) + 3) / 4
+}
+
+// imageTokens approximates the cost of an image. The API downscales large
+// images, so their cost is bounded, unlike their base64 size.
+const imageTokens = 1600
+
+// EstimateHistoryTokens estimates the token size of a history once it is
+// restored into a session. Images count as imageTokens each; documents
+// are estimated from their size.
+func EstimateHistoryTokens(history []core.Message) int {
+	images := 0
+	withoutImages := make([]core.Message, len(history))
+	for i, msg := range history {
+		withoutImages[i] = msg
+		blocks := make([]core.ContentBlock, 0, len(msg.ContentBlocks))
+		for _, block := range msg.ContentBlocks {
+			if block.Type == core.ImageBlockType {
+				images++
+				continue
+			}
+			blocks = append(blocks, block)
+		}
+		withoutImages[i].ContentBlocks = blocks
+	}
+
+	session := NewSession("", "")
+	session.RestoreHistory(withoutImages)
+	return EstimateTokens(session.Messages()) + images*imageTokens
+}
+
+// compactionCut picks the index where kept history starts: the earliest
+// safe cut whose suffix fits in keep tokens, or else the latest safe cut.
+// Returns 0 if there is no safe cut.
+func compactionCut(history []core.Message, sizes []int, keep int) int {
+	suffix := make([]int, len(history)+1)
+	for i := len(history) - 1; i >= 0; i-- {
+		suffix[i] = suffix[i+1] + sizes[i]
+	}
+
+	best := 0
+	open := make(map[string]bool) // tool_use IDs awaiting their result
+	for i := 1; i < len(history); i++ {
+		trackToolPairs(history[i-1], open)
+		if len(open) > 0 || !isTurnStart(history[i]) {
+			continue
+		}
+		best = i
+		if suffix[i] <= keep {
+			return i
+		}
+	}
+	return best
+}
+
+// isTurnStart reports whether msg is a message typed by the user, as
+// opposed to tool results sent on the user's behalf.
+func isTurnStart(msg core.Message) bool {
+	if msg.Role != core.RoleUser || IsSummaryMessage(msg) {
+		return false
+	}
+	hasAttachment := false
+	for _, block := range msg.ContentBlocks {
+		switch block.Type {
+		case core.ToolResultBlockType:
+			return false
+		case core.ImageBlockType, core.DocumentBlockType:
+			hasAttachment = true
+		}
+	}
+	return hasAttachment || msg.GetText() != ""
+}
+
+// trackToolPairs records tool_use blocks in msg as open and closes them
+// when their tool_result appears.
+func trackToolPairs(msg core.Message, open map[string]bool) {
+	for _, block := range msg.ContentBlocks {
+		switch {
+		case block.Type == core.ToolUseBlockType && block.ToolUse != nil:
+			open[block.ToolUse.ID] = true
+		case block.Type == core.ToolResultBlockType && block.ToolResult != nil:
+			delete(open, block.ToolResult.ToolUseID)
+		}
+	}
+}
+
+// summarize asks the compaction model to summarise messages.
+func (m *ContextManager) summarize(ctx context.Context, messages []core.Message) (string, error) {
+	params := anthropic.MessageNewParams{
+		Model:     m.cfg.Model,
+		MaxTokens: m.cfg.MaxSummaryTokens,
+		System: []anthropic.TextBlockParam{
+			{Text: CompactionPrompt},
+		},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock(
+				"Summarise this conversation:\n\n" + transcript(messages),
+			)),
+		},
+	}
+
+	resp, err := m.client.Messages.New(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarise history: %w", err)
+	}
+
+	var summary strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			summary.WriteString(block.Text)
+		}
+	}
+	if strings.TrimSpace(summary.String()) == "" {
+		return "", fmt.Errorf("failed to summarise history: empty summary")
+	}
+	return strings.TrimSpace(summary.String()), nil
+}
+
+// transcript renders messages as plain text for summarisation. Tool
+// results are truncated, as their gist is enough for a summary.
+func transcript(messages []core.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		speaker := "User"
+		if msg.Role == core.RoleAssistant {
+			speaker = "Assistant"
+		}
+		if IsSummaryMessage(msg) {
+			fmt.Fprintf(&b, "Earlier summary: %s\n\n", strings.TrimPrefix(msg.Content, SummaryMessagePrefix))
+			continue
+		}
+		if msg.Content != "" {
+			fmt.Fprintf(&b, "%s: %s\n\n", speaker, msg.Content)
+		}
+		for _, block := range msg.ContentBlocks {
+			switch {
+			case block.Type == core.TextBlockType && block.Text != "":
+				fmt.Fprintf(&b, "%s: %s\n\n", speaker, block.Text)
+			case block.Type == core.ToolUseBlockType && block.ToolUse != nil:
+				fmt.Fprintf(&b, "Assistant called %s with %s\n\n", block.ToolUse.Name, block.ToolUse.Input)
+			case block.Type == core.ToolResultBlockType && block.ToolResult != nil:
+				fmt.Fprintf(&b, "Tool result: %s\n\n", truncateText(block.ToolResult.Content, 500))
+			case (block.Type == core.ImageBlockType || block.Type == core.DocumentBlockType) && block.Attachment != nil:
+				fmt.Fprintf(&b, "%s attached %s %s\n\n", speaker, block.Type, attachmentName(block.Attachment))
+			}
+		}
+	}
+	return b.String()
+}
+
+// attachmentName names an attachment in a transcript.
+func attachmentName(a *core.AttachmentContent) string {
+	if a.Name != "" {
+		return fmt.Sprintf("%q (%s)", a.Name, a.MediaType)
+	}
+	return "(" + a.MediaType + ")"
+}
+
+// truncateText shortens s to at most n bytes, marking the cut.
+func truncateText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
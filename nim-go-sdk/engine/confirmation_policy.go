@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// AutoApproveWindow is the period over which the amounts a policy approved
+// without confirmation are totalled per user.
+const AutoApproveWindow = 24 * time.Hour
+
+// RecipientChecker reports whether the user has paid the recipient of the
+// call before. call.Details is never nil when a checker is called.
+type RecipientChecker func(ctx context.Context, call *core.ConfirmationCall) (bool, error)
+
+// AutoApproveDeposits waives confirmation for savings deposits no larger
+// than the user's UserLimits.AutoApproveDepositMax, up to a total of
+// AutoApproveDepositWindowMax per AutoApproveWindow. Users without a limit
+// are asked as usual.
+func AutoApproveDeposits() core.ConfirmationPolicy {
+	ledger := newApprovalLedger()
+	return func(ctx context.Context, call *core.ConfirmationCall) core.ConfirmationDecision {
+		if call.Context == nil || call.Context.UserLimits == nil {
+			return core.ConfirmationDecision{}
+		}
+		limits := call.Context.UserLimits
+		return ledger.decide(call, core.ActionTypeDeposit, limits.AutoApproveDepositMax, limits.AutoApproveDepositWindowMax)
+	}
+}
+
+// AutoApproveBelow waives confirmation for actions of the given type whose
+// amount is at most max, while the amounts it waived for the user within
+// AutoApproveWindow total at most windowMax, so a large movement split into
+// small calls is still confirmed. An empty windowMax allows a total of max.
+// Actions without structured details, or with an amount that cannot be
+// parsed, are left to other policies.
+//
+// Totals are kept in memory by the policy, so a policy shared by all users
+// of one instance must be created once. Distributed deployments should
+// implement a policy backed by their own ledger.
+func AutoApproveBelow(actionType, max, windowMax string) core.ConfirmationPolicy {
+	ledger := newApprovalLedger()
+	return func(ctx context.Context, call *core.ConfirmationCall) core.ConfirmationDecision {
+		return ledger.decide(call, actionType, max, windowMax)
+	}
+}
+
+// approvalLedger records the amounts a policy approved without
+// confirmation, per user over the last AutoApproveWindow.
+type approvalLedger struct {
+	mu        sync.Mutex
+	approvals map[string][]approvedAmount // userID -> approvals, oldest first
+	sweptAt   time.Time
+}
+
+type approvedAmount struct {
+	at     time.Time
+	amount *big.Rat
+}
+
+func newApprovalLedger() *approvalLedger {
+	return &approvalLedger{approvals: make(map[string][]approvedAmount)}
+}
+
+// decide waives confirmation if the call's amount is within max and keeps
+// the user's total within windowMax, recording the amount as approved.
+func (l *approvalLedger) decide(call *core.ConfirmationCall, actionType, max, windowMax string) core.ConfirmationDecision {
+	if call.Details == nil || call.Details.ActionType != actionType || max == "" {
+		return core.ConfirmationDecision{}
+	}
+	amount, ok := new(big.Rat).SetString(call.Details.Amount)
+	if !ok {
+		return core.ConfirmationDecision{}
+	}
+	limit, ok := new(big.Rat).SetString(max)
+	if !ok || amount.Sign() <= 0 || amount.Cmp(limit) > 0 {
+		return core.ConfirmationDecision{}
+	}
+	total := limit
+	if windowMax != "" {
+		if total, ok = new(big.Rat).SetString(windowMax); !ok {
+			return core.ConfirmationDecision{}
+		}
+	}
+
+	userID := ""
+	if call.Context != nil {
+		userID = call.Context.UserID
+	}
+	if !l.reserve(userID, amount, total, time.Now()) {
+		return core.ConfirmationDecision{}
+	}
+	return core.ConfirmationDecision{
+		Level:  core.ConfirmationNone,
+		Reason: actionType + " within auto-approve limit of " + max,
+	}
+}
+
+// reserve records amount for the user if their total within the window,
+// including amount, stays at most max.
+func (l *approvalLedger) reserve(userID string, amount, max *big.Rat, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.sweptAt) >= AutoApproveWindow {
+		l.sweptAt = now
+		for id, approvals := range l.approvals {
+			if len(recentApprovals(approvals, now)) == 0 {
+				delete(l.approvals, id)
+			}
+		}
+	}
+
+	approvals := recentApprovals(l.approvals[userID], now)
+	total := new(big.Rat).Set(amount)
+	for _, approval := range approvals {
+		total.Add(total, approval.amount)
+	}
+	if total.Cmp(max) > 0 {
+		l.approvals[userID] = approvals
+		return false
+	}
+	l.approvals[userID] = append(approvals, approvedAmount{at: now, amount: amount})
+	return true
+}
+
+// recentApprovals drops approvals older than AutoApproveWindow.
+func recentApprovals(approvals []approvedAmount, now time.Time) []approvedAmount {
+	i := 0
+	for i < len(approvals) && now.Sub(approvals[i].at) >= AutoApproveWindow {
+		i++
+	}
+	return approvals[i:]
+}
+
+// ConfirmNewRecipients requires confirmation for payments to recipients the
+// user has not paid before, even where another policy would waive it.
+// Register it before policies that waive confirmation. If known fails, the
+// payment is confirmed.
+func ConfirmNewRecipients(known RecipientChecker) core.ConfirmationPolicy {
+	return func(ctx context.Context, call *core.ConfirmationCall) core.ConfirmationDecision {
+		if call.Details == nil || call.Details.Recipient == "" {
+			return core.ConfirmationDecision{}
+		}
+		ok, err := known(ctx, call)
+		if err == nil && ok {
+			return core.ConfirmationDecision{}
+		}
+		return core.ConfirmationDecision{
+			Level:  core.ConfirmationRequired,
+			Reason: "new recipient " + call.Details.Recipient,
+		}
+	}
+}
+
+// StepUpAbove requires step-up verification for actions of the given type
+// whose amount exceeds min.
+func StepUpAbove(actionType, min string) core.ConfirmationPolicy {
+	return func(ctx context.Context, call *core.ConfirmationCall) core.ConfirmationDecision {
+		if call.Details == nil || call.Details.ActionType != actionType {
+			return core.ConfirmationDecision{}
+		}
+		amount, ok := new(big.Rat).SetString(call.Details.Amount)
+		limit, limitOK := new(big.Rat).SetString(min)
+		if !ok || !limitOK || amount.Cmp(limit) <= 0 {
+			return core.ConfirmationDecision{}
+		}
+		return core.ConfirmationDecision{
+			Level:  core.ConfirmationStepUp,
+			Reason: actionType + " above " + min,
+		}
+	}
+}
+
+// ShortcutRecipients is a RecipientChecker that treats the recipients
+// saved in the user's Preferences.Shortcuts as known.
+func ShortcutRecipients(ctx context.Context, call *core.ConfirmationCall) (bool, error) {
+	if call.Context == nil || call.Context.Preferences == nil {
+		return false, nil
+	}
+	details := call.Details
+	tag := strings.TrimPrefix(details.Recipient, "@")
+	for name, userID := range call.Context.Preferences.Shortcuts {
+		if strings.EqualFold(name, tag) || userID == details.Recipient ||
+			(details.RecipientUserID != "" && userID == details.RecipientUserID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// confirmationDecision decides how much approval a tool call needs. Engine
+// policies are consulted in registration order, then the tool's own policy,
+// and finally the tool's static RequiresConfirmation.
+func (e *Engine) confirmationDecision(ctx context.Context, call *core.ConfirmationCall) core.ConfirmationDecision {
+	for _, policy := range e.confirmationPolicies {
+		if decision := policy(ctx, call); decision.Level != "" {
+			return decision
+		}
+	}
+	if provider, ok := call.Tool.(core.ConfirmationPolicyProvider); ok {
+		if policy := provider.ConfirmationPolicy(); policy != nil {
+			if decision := policy(ctx, call); decision.Level != "" {
+				return decision
+			}
+		}
+	}
+	return core.StaticConfirmation(call.Tool)
+}
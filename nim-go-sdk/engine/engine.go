@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
 	"github.com/google/uuid"
 )
 
@@ -15,8 +17,16 @@ import (
 type Engine struct {
 	client     *anthropic.Client
 	registry   *ToolRegistry
-	guardrails Guardrails  // Optional: rate limiting and circuit breaker
-	audit      AuditLogger // Optional: audit logging
+	guardrails Guardrails   // Optional: rate limiting and circuit breaker
+	audit      AuditLogger  // Optional: audit logging
+	hooks      hookList     // Optional: lifecycle hooks for tracing and metrics
+	cache      *ResultCache // Optional: result cache for read-only tools
+
+	auditError func(ctx context.Context, entry *AuditEntry, err error) // Optional: audit failure handler
+
+	confirmationPolicies []core.ConfirmationPolicy // Optional: per-call confirmation decisions
+
+	toolConcurrency int // Max read-only tools executed concurrently per turn
 }
 
 // Option configures the engine.
@@ -36,11 +46,56 @@ func WithAudit(a AuditLogger) Option {
 	}
 }
 
+// WithAuditErrorHandler sets the function called when the audit logger
+// fails to record an entry, for example to alert on a compliance sink that
+// has stopped accepting writes. By default failures are logged.
+func WithAuditErrorHandler(h func(ctx context.Context, entry *AuditEntry, err error)) Option {
+	return func(e *Engine) {
+		e.auditError = h
+	}
+}
+
+// WithHooks registers lifecycle hooks. May be given more than once; hooks
+// are called in registration order.
+func WithHooks(h Hooks) Option {
+	return func(e *Engine) {
+		e.hooks = append(e.hooks, h)
+	}
+}
+
+// WithResultCache caches results of read-only tools. Tools that require
+// confirmation always execute.
+func WithResultCache(c *ResultCache) Option {
+	return func(e *Engine) {
+		e.cache = c
+	}
+}
+
+// WithConfirmationPolicy adds a policy that decides per call whether a
+// tool needs confirmation. May be given more than once; the first policy
+// with an opinion decides, so register escalating policies such as
+// ConfirmNewRecipients before waiving ones such as AutoApproveDeposits.
+func WithConfirmationPolicy(p core.ConfirmationPolicy) Option {
+	return func(e *Engine) {
+		e.confirmationPolicies = append(e.confirmationPolicies, p)
+	}
+}
+
+// WithToolConcurrency sets the maximum number of read-only tools executed
+// concurrently when Claude requests several tools in one turn.
+// A value of 1 executes tools sequentially.
+func WithToolConcurrency(n int) Option {
+	return func(e *Engine) {
+		e.toolConcurrency = n
+	}
+}
+
 // NewEngine creates a new engine with the given Anthropic client and registry.
 func NewEngine(client *anthropic.Client, registry *ToolRegistry, opts ...Option) *Engine {
 	e := &Engine{
-		client:   client,
-		registry: registry,
+		client:          client,
+		registry:        registry,
+		toolConcurrency: DefaultToolConcurrency,
 	}
 	for _, opt := range opts {
 		opt(e)
@@ -58,12 +113,28 @@ type Input struct {
 	// UserMessage is the user's message to process.
 	UserMessage string
 
+	// Attachments are images and documents sent with UserMessage. Validate
+	// them with core.AttachmentLimits before running.
+	Attachments []core.AttachmentContent
+
 	// Context contains user identity, preferences, and execution limits.
 	Context *core.Context
 
 	// History contains previous messages in the conversation.
 	History []core.Message
 
+	// ToolResults resumes a run that paused for confirmation. It contains the
+	// results for the pending tool_use blocks at the end of History (e.g., a
+	// confirmed or cancelled write) and is sent in place of UserMessage so the
+	// agent continues from where it paused.
+	ToolResults []core.ToolResultContent
+
+	// PriorToolCalls counts the tool calls a paused request already made,
+	// by tool name. Set it from Output.ToolCalls when resuming, so
+	// MaxToolCalls and ToolQuotas apply across the confirmation pause
+	// instead of restarting.
+	PriorToolCalls map[string]int
+
 	// SystemPrompt is the system prompt to use.
 	SystemPrompt string
 
@@ -83,6 +154,23 @@ type Input struct {
 
 	// StreamCallback is an optional callback for streaming responses.
 	StreamCallback func(chunk string, done bool)
+
+	// ThinkingBudget enables extended thinking with the given token budget,
+	// at least 1024. Thinking blocks are kept in the session across
+	// tool-use turns, as the API requires. MaxTokens covers the response
+	// only; the budget is added to it. Zero disables thinking.
+	ThinkingBudget int64
+
+	// EventCallback optionally receives progress events: turn starts, text
+	// and thinking deltas, tool executions and sub-agent delegations.
+	// Without StreamCallback the response is not streamed, and each text or
+	// thinking block is reported as a single delta.
+	EventCallback EventCallback
+
+	// PromptCaching enables cache_control breakpoints on the system prompt,
+	// tool definitions and conversation prefix. Cache token counts are
+	// reported in Output.TokensUsed.
+	PromptCaching bool
 }
 
 // Output represents the output from an agent run.
@@ -94,11 +182,27 @@ type Output struct {
 	Text string
 
 	// PendingAction is set when Type is OutputConfirmationNeeded.
+	// It is the first of PendingActions.
 	PendingAction *core.PendingAction
 
+	// PendingActions lists every write in the paused turn awaiting
+	// confirmation, in block order. Each can be approved or rejected
+	// individually.
+	PendingActions []*core.PendingAction
+
+	// ToolResults contains the results of the other tool_use blocks in the
+	// paused turn (read tools that already ran, rejected calls). They must be
+	// sent back together with the results of PendingActions when resuming.
+	ToolResults []core.ToolResultContent
+
 	// ToolsUsed records all tools invoked during this run.
 	ToolsUsed []core.ToolExecution
 
+	// ToolCalls counts the tool calls made by the request so far, by tool
+	// name, including Input.PriorToolCalls. Set when Type is
+	// OutputConfirmationNeeded.
+	ToolCalls map[string]int
+
 	// ResponseBlocks contains the full response for persistence.
 	ResponseBlocks []core.ContentBlock
 
@@ -121,11 +225,40 @@ const (
 
 	// OutputError indicates an error occurred.
 	OutputError
+
+	// OutputInterrupted indicates the run's context was cancelled, for
+	// example because the user stopped it. Text holds the text produced
+	// before the interruption and Error the cancellation cause.
+	OutputInterrupted
 )
 
 // Run executes the agent loop until completion or confirmation is needed.
 func (e *Engine) Run(ctx context.Context, input *Input) (*Output, error) {
-	// Check guardrails if configured
+	if len(e.hooks) == 0 {
+		return e.run(ctx, input)
+	}
+
+	info := &RunInfo{
+		AgentName: input.AgentName,
+		Resumed:   len(input.ToolResults) > 0,
+		Start:     time.Now(),
+	}
+	ctx = e.hooks.runStart(ctx, input.Context, info)
+
+	output, err := e.run(ctx, input)
+
+	info.Duration = time.Since(info.Start)
+	info.Output = output
+	info.Error = err
+	e.hooks.runEnd(ctx, input.Context, info)
+	return output, err
+}
+
+// run implements Run.
+func (e *Engine) run(ctx context.Context, input *Input) (*Output, error) {
+	// Check guardrails if configured. A run admitted while the circuit is
+	// half-open is a trial whose success closes it.
+	trial := false
 	if e.guardrails != nil && input.Context != nil {
 		result, err := e.guardrails.Check(ctx, input.Context.UserID)
 		if err != nil {
@@ -137,9 +270,10 @@ func (e *Engine) Run(ctx context.Context, input *Input) (*Output, error) {
 		if !result.Allowed {
 			return &Output{
 				Type:  OutputError,
-				Error: fmt.Errorf("request blocked by guardrails: %s", result.Warning),
+				Error: &GuardrailError{Result: result},
 			}, nil
 		}
+		trial = result.CircuitState == CircuitHalfOpen
 	}
 
 	// Apply defaults
@@ -151,6 +285,11 @@ func (e *Engine) Run(ctx context.Context, input *Input) (*Output, error) {
 	if maxTokens == 0 {
 		maxTokens = 4096
 	}
+	var thinking anthropic.ThinkingConfigParamUnion
+	if input.ThinkingBudget > 0 {
+		thinking = anthropic.ThinkingConfigParamOfEnabled(input.ThinkingBudget)
+		maxTokens += input.ThinkingBudget
+	}
 	systemPrompt := input.SystemPrompt
 	if systemPrompt == "" {
 		systemPrompt = DefaultSystemPrompt
@@ -159,7 +298,9 @@ func (e *Engine) Run(ctx context.Context, input *Input) (*Output, error) {
 	// Get limits from context
 	maxTurns := 20
 	canConfirm := true
+	var limits *core.ExecutionLimits
 	if input.Context != nil && input.Context.Limits != nil {
+		limits = input.Context.Limits
 		maxTurns = input.Context.Limits.MaxTurns
 		canConfirm = input.Context.Limits.CanConfirm
 		if input.Context.Limits.Timeout > 0 {
@@ -181,11 +322,18 @@ func (e *Engine) Run(ctx context.Context, input *Input) (*Output, error) {
 	// Track cumulative token usage
 	var totalTokens core.TokenUsage
 
+	// Track tool calls across turns
+	quota := newToolQuota(limits, input.PriorToolCalls)
+
 	// Restore history
 	session.RestoreHistory(input.History)
 
-	// Add user message
-	if input.UserMessage != "" {
+	// Add results for a resumed run, or the new user message
+	if len(input.ToolResults) > 0 {
+		session.RestoreHistory([]core.Message{core.NewToolResultMessage(input.ToolResults)})
+	} else if len(input.Attachments) > 0 {
+		session.RestoreHistory([]core.Message{core.NewUserMessageWithAttachments(input.UserMessage, input.Attachments)})
+	} else if input.UserMessage != "" {
 		session.AddUserMessage(input.UserMessage)
 	}
 
@@ -197,20 +345,41 @@ func (e *Engine) Run(ctx context.Context, input *Input) (*Output, error) {
 		apiTools = e.registry.ToAPITools()
 	}
 
+	system := []anthropic.TextBlockParam{
+		{Text: systemPrompt},
+	}
+	if input.PromptCaching {
+		cacheSystemPrompt(system)
+		cacheTools(apiTools)
+	}
+
 	// Get agent name for audit logging
 	agentName := input.AgentName
 	if agentName == "" {
 		agentName = "default"
 	}
-
-	// Get parent ID for audit chain
-	var auditParentID *string
-	if input.Context != nil && input.Context.AuditParentID != nil {
-		auditParentID = input.Context.AuditParentID
+	audit := newAuditScope(input, session, agentName)
+
+	// Text produced so far across all turns, kept if the run is interrupted
+	var runText strings.Builder
+	streamCallback := input.StreamCallback
+	if streamCallback != nil {
+		streamCallback = func(chunk string, done bool) {
+			runText.WriteString(chunk)
+			if chunk != "" {
+				input.emit(Event{Type: EventTextDelta, Text: chunk})
+			}
+			input.StreamCallback(chunk, done)
+		}
 	}
 
+	var allToolsUsed []core.ToolExecution
+
 	for {
 		// Check context cancellation
+		if interrupted(ctx) {
+			return e.interruptedOutput(ctx, audit, runText.String(), allToolsUsed, totalTokens), nil
+		}
 		if ctx.Err() != nil {
 			return &Output{
 				Type:       OutputError,
@@ -229,32 +398,48 @@ func (e *Engine) Run(ctx context.Context, input *Input) (*Output, error) {
 		}
 
 		session.IncrementTurnCount()
+		input.emit(Event{Type: EventTurnStart, Turn: session.TurnCount})
 
 		// Build the message request
 		params := anthropic.MessageNewParams{
 			Model:     anthropic.Model(model),
 			MaxTokens: maxTokens,
 			Messages:  session.Messages(),
-			System: []anthropic.TextBlockParam{
-				{Text: systemPrompt},
-			},
+			System:    system,
+			Thinking:  thinking,
 		}
 
 		if len(apiTools) > 0 {
 			params.Tools = apiTools
 		}
 
+		restoreCache := func() {}
+		if input.PromptCaching {
+			restoreCache = cacheConversation(params.Messages)
+		}
+
 		// Call Claude API
 		var resp *anthropic.Message
 		var err error
 
-		if input.StreamCallback != nil {
-			resp, err = e.createMessageStreaming(ctx, params, input.StreamCallback)
+		start := time.Now()
+		if streamCallback != nil {
+			resp, err = e.createMessageStreaming(ctx, params, streamCallback, func(chunk string) {
+				input.emit(Event{Type: EventThinkingDelta, Text: chunk})
+			})
 		} else {
 			resp, err = e.client.Messages.New(ctx, params)
 		}
+		restoreCache()
+		turn := newTurnInfo(session.TurnCount, model, start, resp, err)
+		e.auditModelTurn(ctx, audit, turn)
+		e.hooks.turn(ctx, input.Context, turn)
 
+		if err != nil && interrupted(ctx) {
+			return e.interruptedOutput(ctx, audit, runText.String(), allToolsUsed, totalTokens), nil
+		}
 		if err != nil {
+			e.recordFailure(ctx, input.Context)
 			return &Output{
 				Type:       OutputError,
 				Error:      fmt.Errorf("claude API error: %w", err),
@@ -265,153 +450,216 @@ func (e *Engine) Run(ctx context.Context, input *Input) (*Output, error) {
 		// Accumulate token usage
 		totalTokens.InputTokens += int(resp.Usage.InputTokens)
 		totalTokens.OutputTokens += int(resp.Usage.OutputTokens)
+		totalTokens.CacheCreationInputTokens += int(resp.Usage.CacheCreationInputTokens)
+		totalTokens.CacheReadInputTokens += int(resp.Usage.CacheReadInputTokens)
 
-		// Process response blocks
-		var toolResults []anthropic.ContentBlockParamUnion
+		// Plan tool calls in block order. Read-only tools are executed
+		// concurrently once planning is complete; writes become pending
+		// actions awaiting user confirmation.
 		var textResponse string
-		var toolsUsed []core.ToolExecution
-		var confirmationNeeded *core.PendingAction
+		var planned []*toolCall
 
 		for _, block := range resp.Content {
 			switch block.Type {
 			case "text":
 				textResponse += block.Text
+				if streamCallback == nil {
+					runText.WriteString(block.Text)
+					input.emit(Event{Type: EventTextDelta, Text: block.Text})
+				}
+
+			case "thinking":
+				if streamCallback == nil {
+					input.emit(Event{Type: EventThinkingDelta, Text: block.Thinking})
+				}
 
 			case "tool_use":
 				toolName := block.Name
-				toolInput := block.Input
+				inputBytes, _ := json.Marshal(block.Input)
+				call := &toolCall{id: block.ID, name: toolName, input: inputBytes}
+				planned = append(planned, call)
 
 				tool, ok := e.registry.Get(toolName)
 				if !ok {
-					toolResults = append(toolResults, anthropic.NewToolResultBlock(
-						block.ID,
-						fmt.Sprintf("unknown tool: %s", toolName),
-						true,
-					))
+					call.rejection = fmt.Sprintf("unknown tool: %s", toolName)
 					continue
 				}
+				call.tool = tool
 
-				// Check if write operation requiring confirmation
-				if tool.RequiresConfirmation() {
-					if !canConfirm {
-						toolResults = append(toolResults, anthropic.NewToolResultBlock(
-							block.ID,
-							"error: this operation requires user confirmation",
-							true,
-						))
-						continue
-					}
-
-					inputBytes, _ := json.Marshal(toolInput)
-					confirmationNeeded = &core.PendingAction{
-						ID:             uuid.New().String(),
-						IdempotencyKey: GenerateIdempotencyKey(session.UserID, toolName, inputBytes),
-						SessionID:      session.ID,
-						UserID:         session.UserID,
-						Tool:           toolName,
-						Input:          inputBytes,
-						Summary:        tool.GetSummary(inputBytes),
-						BlockID:        block.ID,
-						CreatedAt:      time.Now().Unix(),
-						ExpiresAt:      time.Now().Add(10 * time.Minute).Unix(),
-					}
-					break
+				// Reject input that does not match the tool's schema so
+				// Claude can correct it without the tool running
+				if err := tools.ValidateInput(tool.Schema(), inputBytes); err != nil {
+					call.rejection = fmt.Sprintf("error: invalid input: %v", err)
+					continue
 				}
 
-				// Execute read-only tool
-				startTime := time.Now()
-				inputBytes, _ := json.Marshal(toolInput)
-
-				result, err := tool.Execute(ctx, &core.ToolParams{
-					UserID:    session.UserID,
-					Input:     inputBytes,
-					RequestID: session.ID,
-				})
+				// Enforce tool call limits
+				if msg, ok := quota.take(toolName); !ok {
+					call.rejection = msg
+					continue
+				}
 
-				durationMs := time.Since(startTime).Milliseconds()
-				execution := core.ToolExecution{
-					Tool:       toolName,
-					Input:      toolInput,
-					DurationMs: durationMs,
+				// Agents that cannot ask the user, such as sub-agents,
+				// never write, even where a policy would waive confirmation
+				if tool.RequiresConfirmation() && !canConfirm {
+					call.rejection = "error: this operation requires user confirmation"
+					continue
 				}
+			}
+		}
+
+		// Gather structured details for the remaining calls, running their
+		// preflight reads concurrently, so policies can decide on them
+		e.describeActions(ctx, planned, session.UserID, audit.requestID)
+
+		// Decide in block order whether each call needs approval, letting
+		// policies waive or escalate the tool's static requirement
+		var calls []*toolCall
+		var pendingActions []*core.PendingAction
+		for _, call := range planned {
+			if call.rejection != "" {
+				calls = append(calls, call)
+				continue
+			}
+			tool := call.tool
+
+			// The same write requested twice in one turn is only
+			// performed once
+			idempotencyKey := GenerateIdempotencyKey(session.UserID, call.name, call.input)
+			if tool.RequiresConfirmation() && hasWaivedWrite(calls, idempotencyKey) {
+				call.rejection = "error: duplicate of another action in this turn; it will only be performed once"
+				calls = append(calls, call)
+				continue
+			}
 
-				// Log audit entry if configured
-				if e.audit != nil {
-					var outputBytes json.RawMessage
-					var errStr *string
-					if result != nil {
-						outputBytes, _ = json.Marshal(result.Data)
-						if result.Error != "" {
-							errStr = &result.Error
-						}
-					}
-					if err != nil {
-						errMsg := err.Error()
-						errStr = &errMsg
-					}
-					e.audit.Log(ctx, &AuditEntry{
-						ID:         uuid.New().String(),
-						UserID:     session.UserID,
-						SessionID:  session.ID,
-						RequestID:  session.ID,
-						ParentID:   auditParentID,
-						AgentName:  agentName,
-						ToolName:   toolName,
-						ToolInput:  inputBytes,
-						ToolOutput: outputBytes,
-						Error:      errStr,
-						DurationMs: durationMs,
-						IsWriteOp:  tool.RequiresConfirmation(),
-						Timestamp:  startTime.Unix(),
-					})
+			decision := e.confirmationDecision(ctx, &core.ConfirmationCall{
+				Tool:    tool,
+				Input:   call.input,
+				Context: input.Context,
+				Details: call.details,
+			})
+
+			if decision.Level == core.ConfirmationNone && tool.RequiresConfirmation() {
+				// Waived writes execute now, as if the user had confirmed
+				call.write = true
+				call.confirmationID = uuid.New().String()
+				call.idempotencyKey = idempotencyKey
+				call.reason = decision.Reason
+			}
+
+			if decision.Level == core.ConfirmationRequired || decision.Level == core.ConfirmationStepUp {
+				if !canConfirm {
+					call.rejection = "error: this operation requires user confirmation"
+					calls = append(calls, call)
+					continue
 				}
 
-				if err != nil {
-					execution.Error = err.Error()
-					toolResults = append(toolResults, anthropic.NewToolResultBlock(
-						block.ID,
-						err.Error(),
-						true,
-					))
-				} else if result != nil && !result.Success {
-					execution.Error = result.Error
-					toolResults = append(toolResults, anthropic.NewToolResultBlock(
-						block.ID,
-						result.Error,
-						true,
-					))
-				} else {
-					if result != nil {
-						execution.Result = result.Data
-					}
-					resultBytes, _ := json.Marshal(result.Data)
-					toolResults = append(toolResults, anthropic.NewToolResultBlock(
-						block.ID,
-						string(resultBytes),
-						false,
-					))
+				// The same write requested twice in one turn is only
+				// offered for confirmation once
+				if hasIdempotencyKey(pendingActions, idempotencyKey) {
+					call.rejection = "error: duplicate of another action in this turn; it will only be performed once"
+					calls = append(calls, call)
+					continue
 				}
 
-				toolsUsed = append(toolsUsed, execution)
+				pendingActions = append(pendingActions, &core.PendingAction{
+					ID:                 uuid.New().String(),
+					IdempotencyKey:     idempotencyKey,
+					SessionID:          audit.sessionID,
+					UserID:             session.UserID,
+					Tool:               call.name,
+					Input:              call.input,
+					Summary:            tool.GetSummary(call.input),
+					Details:            call.details,
+					ConfirmationLevel:  decision.Level,
+					ConfirmationReason: decision.Reason,
+					BlockID:            call.id,
+					RequestID:          audit.requestID,
+					AgentName:          agentName,
+					CreatedAt:          time.Now().Unix(),
+					ExpiresAt:          time.Now().Add(10 * time.Minute).Unix(),
+				})
+				continue
 			}
 
-			if confirmationNeeded != nil {
-				break
+			calls = append(calls, call)
+		}
+
+		// Execute read-only tools
+		e.executeToolCalls(withEventCallback(ctx, input.EventCallback), session, input.Context, audit.requestID, calls, e.toolConcurrency)
+
+		// Collect results, audit entries and executions in block order
+		var toolResults []anthropic.ContentBlockParamUnion
+		var toolsUsed []core.ToolExecution
+		for _, call := range calls {
+			toolResults = append(toolResults, call.resultBlock())
+			if !call.executed() {
+				continue
+			}
+
+			if call.write {
+				e.logAudit(ctx, audit, call.waivedAuditEntry())
+			}
+			e.logAudit(ctx, audit, call.auditEntry())
+
+			// Only execution errors count; a tool refusing the request,
+			// such as for insufficient funds, is not a failure
+			if call.err == nil || !interrupted(ctx) {
+				e.recordToolResult(ctx, session.UserID, call.name, call.err)
 			}
+
+			toolsUsed = append(toolsUsed, call.execution())
+		}
+		allToolsUsed = append(allToolsUsed, toolsUsed...)
+
+		// A run stopped while tools were executing ends once they have
+		// finished or been cancelled
+		if interrupted(ctx) {
+			return e.interruptedOutput(ctx, audit, runText.String(), allToolsUsed, totalTokens), nil
 		}
 
 		// Build response blocks for persistence
 		responseBlocks := responseToBlocks(resp)
 
-		// If confirmation needed, return for user approval
-		if confirmationNeeded != nil {
+		// Several writes in one turn form a plan that can be approved at once
+		if len(pendingActions) > 1 {
+			planID := uuid.New().String()
+			for i, action := range pendingActions {
+				action.PlanID = planID
+				action.PlanStep = i + 1
+			}
+		}
+
+		// If confirmation needed, return for user approval along with the
+		// results of the tools that already ran in this turn
+		if len(pendingActions) > 0 {
+			for _, action := range pendingActions {
+				entry := actionAuditEntry(AuditEventConfirmationCreated, action)
+				entry.Detail = confirmationDetail(action.ConfirmationLevel, action.ConfirmationReason)
+				e.logAudit(ctx, audit, entry)
+			}
+			e.hooks.confirmationNeeded(ctx, input.Context, pendingActions)
+
+			// Pausing for confirmation is a successful run
+			if trial {
+				e.recordSuccess(ctx, input.Context)
+			}
+
 			session.AddAssistantResponse(resp)
 
+			results := make([]core.ToolResultContent, len(calls))
+			for i, call := range calls {
+				results[i] = call.resultContent()
+			}
+
 			return &Output{
 				Type:           OutputConfirmationNeeded,
 				Text:           textResponse,
-				PendingAction:  confirmationNeeded,
+				PendingAction:  pendingActions[0],
+				PendingActions: pendingActions,
+				ToolResults:    results,
 				ToolsUsed:      toolsUsed,
+				ToolCalls:      quota.calls(),
 				ResponseBlocks: responseBlocks,
 				TokensUsed:     totalTokens,
 			}, nil
@@ -425,9 +673,9 @@ func (e *Engine) Run(ctx context.Context, input *Input) (*Output, error) {
 				input.StreamCallback("", true)
 			}
 
-			// Record success with guardrails
-			if e.guardrails != nil && input.Context != nil {
-				e.guardrails.RecordSuccess(ctx, input.Context.UserID)
+			// A successful trial run closes the circuit
+			if trial {
+				e.recordSuccess(ctx, input.Context)
 			}
 
 			return &Output{
@@ -451,16 +699,101 @@ func (e *Engine) ExecuteTool(ctx context.Context, userID, toolName string, input
 		return nil, fmt.Errorf("unknown tool: %s", toolName)
 	}
 
-	return tool.Execute(ctx, &core.ToolParams{
+	start := time.Now()
+	result, err := tool.Execute(ctx, &core.ToolParams{
 		UserID:         userID,
+		ToolName:       toolName,
 		Input:          input,
 		ConfirmationID: confirmationID,
+		IdempotencyKey: confirmationID,
 		RequestID:      confirmationID,
 	})
+	e.recordToolResult(ctx, userID, toolName, err)
+	e.auditActionExecuted(ctx, &core.PendingAction{
+		ID:        confirmationID,
+		UserID:    userID,
+		Tool:      toolName,
+		Input:     input,
+		RequestID: confirmationID,
+	}, start, result, err)
+	return result, err
+}
+
+// recordSuccess reports a successful trial run to the guardrails.
+func (e *Engine) recordSuccess(ctx context.Context, agentCtx *core.Context) {
+	if e.guardrails != nil && agentCtx != nil {
+		e.guardrails.RecordSuccess(ctx, agentCtx.UserID)
+	}
+}
+
+// recordFailure reports a Claude API failure to the guardrails.
+func (e *Engine) recordFailure(ctx context.Context, agentCtx *core.Context) {
+	if e.guardrails != nil && agentCtx != nil {
+		e.guardrails.RecordFailure(ctx, agentCtx.UserID)
+	}
+}
+
+// recordToolResult reports a tool execution to the guardrails. Without
+// ToolGuardrails only failures are reported.
+func (e *Engine) recordToolResult(ctx context.Context, userID, toolName string, err error) {
+	if e.guardrails == nil {
+		return
+	}
+	if tg, ok := e.guardrails.(ToolGuardrails); ok {
+		if err != nil {
+			tg.RecordToolFailure(ctx, userID, toolName)
+		} else {
+			tg.RecordToolSuccess(ctx, userID, toolName)
+		}
+		return
+	}
+	if err != nil {
+		e.guardrails.RecordFailure(ctx, userID)
+	}
+}
+
+// ExecuteAction executes a confirmed pending action, passing its ID through
+// to the tool as the idempotency key. The execution is audited under the
+// request that proposed the action.
+func (e *Engine) ExecuteAction(ctx context.Context, action *core.PendingAction) (*core.ToolResult, error) {
+	tool, ok := e.registry.Get(action.Tool)
+	if !ok {
+		return nil, fmt.Errorf("unknown tool: %s", action.Tool)
+	}
+
+	agentCtx := &core.Context{
+		UserID:    action.UserID,
+		SessionID: action.SessionID,
+		RequestID: action.RequestID,
+	}
+	info := &ToolInfo{
+		Name:    action.Tool,
+		Input:   action.Input,
+		IsWrite: true,
+		Start:   time.Now(),
+	}
+	toolCtx := e.hooks.toolStart(ctx, agentCtx, info)
+
+	result, err := tool.Execute(toolCtx, &core.ToolParams{
+		UserID:         action.UserID,
+		ToolName:       action.Tool,
+		Input:          action.Input,
+		ConfirmationID: action.ID,
+		IdempotencyKey: action.ID,
+		RequestID:      action.ID,
+	})
+
+	info.Duration = time.Since(info.Start)
+	info.Result, info.Error = result, err
+	e.hooks.toolEnd(toolCtx, agentCtx, info)
+	e.auditActionExecuted(ctx, action, info.Start, result, err)
+	e.recordToolResult(ctx, action.UserID, action.Tool, err)
+	return result, err
 }
 
-// createMessageStreaming handles streaming API calls.
-func (e *Engine) createMessageStreaming(ctx context.Context, params anthropic.MessageNewParams, callback func(string, bool)) (*anthropic.Message, error) {
+// createMessageStreaming handles streaming API calls. Text deltas are passed
+// to callback and extended thinking deltas to thinking.
+func (e *Engine) createMessageStreaming(ctx context.Context, params anthropic.MessageNewParams, callback func(string, bool), thinking func(string)) (*anthropic.Message, error) {
 	stream := e.client.Messages.NewStreaming(ctx, params)
 	defer stream.Close()
 
@@ -481,6 +814,8 @@ func (e *Engine) createMessageStreaming(ctx context.Context, params anthropic.Me
 			switch delta := evt.Delta.AsAny().(type) {
 			case anthropic.TextDelta:
 				callback(delta.Text, false)
+			case anthropic.ThinkingDelta:
+				thinking(delta.Thinking)
 			}
 		case anthropic.MessageStopEvent:
 			// Stream complete
@@ -504,6 +839,10 @@ func responseToBlocks(resp *anthropic.Message) []core.ContentBlock {
 		case "tool_use":
 			inputBytes, _ := json.Marshal(block.Input)
 			blocks = append(blocks, core.NewToolUseBlock(block.ID, block.Name, inputBytes))
+		case "thinking":
+			blocks = append(blocks, core.NewThinkingBlock(block.Thinking, block.Signature))
+		case "redacted_thinking":
+			blocks = append(blocks, core.NewRedactedThinkingBlock(block.Data))
 		}
 	}
 	return blocks
@@ -517,6 +856,7 @@ func (e *Engine) RunAgent(ctx context.Context, agent core.Agent, input *core.Inp
 	// Build engine input from core input and agent capabilities
 	engineInput := &Input{
 		UserMessage:    input.UserMessage,
+		Attachments:    input.Attachments,
 		Context:        input.Context,
 		History:        input.History,
 		SystemPrompt:   caps.SystemPrompt,
@@ -524,6 +864,8 @@ func (e *Engine) RunAgent(ctx context.Context, agent core.Agent, input *core.Inp
 		MaxTokens:      caps.MaxTokens,
 		AgentName:      agent.Name(),
 		AvailableTools: caps.AvailableTools,
+		PromptCaching:  caps.PromptCaching,
+		ThinkingBudget: caps.ThinkingBudget,
 	}
 
 	// Override context limits with agent capabilities if not already set
@@ -551,6 +893,8 @@ func (e *Engine) RunAgent(ctx context.Context, agent core.Agent, input *core.Inp
 		Type:           core.OutputType(output.Type),
 		Text:           output.Text,
 		PendingAction:  output.PendingAction,
+		PendingActions: output.PendingActions,
+		ToolResults:    output.ToolResults,
 		ToolsUsed:      output.ToolsUsed,
 		ResponseBlocks: output.ResponseBlocks,
 		TokensUsed:     output.TokensUsed,
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	// EventTurnStart marks the start of a turn, before Claude is called.
+	EventTurnStart EventType = "turn_start"
+
+	// EventTextDelta carries a chunk of Claude's response text.
+	EventTextDelta EventType = "text_delta"
+
+	// EventThinkingDelta carries a chunk of Claude's extended thinking.
+	EventThinkingDelta EventType = "thinking_delta"
+
+	// EventToolStart is emitted when a tool starts executing.
+	EventToolStart EventType = "tool_start"
+
+	// EventToolEnd is emitted when a tool has finished executing.
+	EventToolEnd EventType = "tool_end"
+
+	// EventSubAgentStart is emitted when a task is delegated to a sub-agent.
+	EventSubAgentStart EventType = "subagent_start"
+
+	// EventSubAgentEnd is emitted when a sub-agent has finished its task.
+	EventSubAgentEnd EventType = "subagent_end"
+)
+
+// Event reports progress of a run, so clients can show what the agent is
+// doing while it works. Only the fields relevant to the Type are set.
+type Event struct {
+	// Type is the kind of event.
+	Type EventType
+
+	// Turn is the 1-based turn number. Set for EventTurnStart.
+	Turn int
+
+	// Text is the response or thinking text chunk. Set for EventTextDelta
+	// and EventThinkingDelta.
+	Text string
+
+	// ToolUseID is Claude's tool_use block ID. Set for tool events.
+	ToolUseID string
+
+	// ToolName is the tool being executed. Set for tool events.
+	ToolName string
+
+	// Summary describes the tool call from its input, such as
+	// "Get transactions: limit 10". Set for EventToolStart.
+	Summary string
+
+	// AgentName is the sub-agent the task was delegated to. Set for
+	// sub-agent events.
+	AgentName string
+
+	// Duration is the execution time. Set for end events.
+	Duration time.Duration
+
+	// Success reports whether the tool or sub-agent succeeded. Set for end
+	// events.
+	Success bool
+
+	// Error describes the failure, if any. Set for end events.
+	Error string
+}
+
+// EventCallback receives the events of a run. It is called concurrently
+// when several read-only tools run in one turn, so it must be safe for
+// concurrent use, and it should return quickly.
+type EventCallback func(event Event)
+
+// emit reports an event to the caller, if it asked for events.
+func (in *Input) emit(event Event) {
+	if in.EventCallback != nil {
+		in.EventCallback(event)
+	}
+}
+
+type eventCallbackKey struct{}
+
+// withEventCallback returns a context that carries the run's event
+// callback to tools, so they can report progress with EmitEvent. A nil
+// callback hides the callback of any enclosing run.
+func withEventCallback(ctx context.Context, callback EventCallback) context.Context {
+	return context.WithValue(ctx, eventCallbackKey{}, callback)
+}
+
+// EmitEvent reports an event to the run that is executing the tool. Tools
+// receive the run's event callback through their context; outside a run,
+// or when the caller did not ask for events, EmitEvent does nothing.
+func EmitEvent(ctx context.Context, event Event) {
+	if callback, ok := ctx.Value(eventCallbackKey{}).(EventCallback); ok && callback != nil {
+		callback(event)
+	}
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"fmt"
 )
 
 // Guardrails provides rate limiting and circuit breaker functionality.
@@ -12,8 +13,9 @@ type Guardrails interface {
 	// Returns a result indicating if the request is allowed and any warnings.
 	Check(ctx context.Context, userID string) (*GuardrailResult, error)
 
-	// RecordSuccess records a successful operation for the user.
-	// This is used to track usage and reset circuit breakers.
+	// RecordSuccess records that a run admitted while the circuit was
+	// half-open succeeded, so the circuit can close. Runs admitted while
+	// the circuit was closed do not record success.
 	RecordSuccess(ctx context.Context, userID string)
 
 	// RecordFailure records a failed operation for the user.
@@ -21,6 +23,18 @@ type Guardrails interface {
 	RecordFailure(ctx context.Context, userID string)
 }
 
+// ToolGuardrails is optionally implemented by Guardrails to count failures
+// per tool. The engine reports every tool execution through it, so a tool
+// that keeps failing is not masked by other tools succeeding. Without it,
+// tool failures are reported with RecordFailure.
+type ToolGuardrails interface {
+	// RecordToolSuccess records a successful execution of the tool.
+	RecordToolSuccess(ctx context.Context, userID, toolName string)
+
+	// RecordToolFailure records a failed execution of the tool.
+	RecordToolFailure(ctx context.Context, userID, toolName string)
+}
+
 // GuardrailResult contains the result of a guardrail check.
 type GuardrailResult struct {
 	// Allowed indicates whether the request should proceed.
@@ -41,6 +55,17 @@ type GuardrailResult struct {
 	RetryAfter int64 // Unix timestamp
 }
 
+// GuardrailError is set as Output.Error when guardrails block a request.
+type GuardrailError struct {
+	// Result is the guardrail check that blocked the request.
+	Result *GuardrailResult
+}
+
+// Error returns the blocking reason.
+func (e *GuardrailError) Error() string {
+	return fmt.Sprintf("request blocked by guardrails: %s", e.Result.Warning)
+}
+
 // NoOpGuardrails is a guardrails implementation that allows everything.
 // Useful for development and testing.
 type NoOpGuardrails struct{}
@@ -0,0 +1,280 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states reported in GuardrailResult.CircuitState.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// MemoryGuardrailsConfig configures MemoryGuardrails.
+type MemoryGuardrailsConfig struct {
+	// RequestsPerMinute is the rate at which each user's token bucket refills.
+	RequestsPerMinute float64
+
+	// Burst is the bucket capacity: the number of requests a user can make
+	// in quick succession.
+	Burst int
+
+	// WarningThreshold is the number of remaining requests at or below which
+	// a warning is returned with an allowed result.
+	WarningThreshold int
+
+	// FailureThreshold is the number of consecutive failures of one tool,
+	// or of runs failing outside tools, that opens the circuit for a user.
+	FailureThreshold int
+
+	// OpenDuration is how long the circuit stays open before allowing
+	// trial requests.
+	OpenDuration time.Duration
+
+	// HalfOpenRequests is the number of trial requests allowed while the
+	// circuit is half-open. If none of them records a success or failure,
+	// new trials are allowed after OpenDuration.
+	HalfOpenRequests int
+
+	// IdleTimeout is how long a user's state is kept without activity once
+	// the circuit is closed and the bucket has refilled. Failure counts of
+	// an evicted user are forgotten. Defaults to 10 minutes.
+	IdleTimeout time.Duration
+}
+
+// DefaultMemoryGuardrailsConfig returns sensible defaults for MemoryGuardrails.
+func DefaultMemoryGuardrailsConfig() *MemoryGuardrailsConfig {
+	return &MemoryGuardrailsConfig{
+		RequestsPerMinute: 20,
+		Burst:             10,
+		WarningThreshold:  2,
+		FailureThreshold:  5,
+		OpenDuration:      30 * time.Second,
+		HalfOpenRequests:  1,
+		IdleTimeout:       10 * time.Minute,
+	}
+}
+
+// MemoryGuardrails is an in-memory Guardrails implementation with a per-user
+// token-bucket rate limiter and a closed/open/half-open circuit breaker.
+// Suitable for single-instance deployments. Distributed deployments should
+// implement Guardrails with Redis or similar.
+type MemoryGuardrails struct {
+	cfg     MemoryGuardrailsConfig
+	mu      sync.Mutex
+	users   map[string]*userGuardrails
+	sweptAt time.Time
+}
+
+// runFailures is the failures key for failures outside tools, such as
+// Claude API errors.
+const runFailures = ""
+
+// userGuardrails is the rate limit and circuit state for one user.
+type userGuardrails struct {
+	tokens     float64
+	refilledAt time.Time
+	seenAt     time.Time
+
+	state    string
+	failures map[string]int // consecutive failures by tool name
+	openedAt time.Time
+	trials   int
+	trialAt  time.Time // when the last half-open trial was allowed
+}
+
+// NewMemoryGuardrails creates an in-memory guardrails implementation.
+func NewMemoryGuardrails(cfg *MemoryGuardrailsConfig) *MemoryGuardrails {
+	if cfg == nil {
+		cfg = DefaultMemoryGuardrailsConfig()
+	}
+	g := &MemoryGuardrails{
+		cfg:   *cfg,
+		users: make(map[string]*userGuardrails),
+	}
+	if g.cfg.IdleTimeout <= 0 {
+		g.cfg.IdleTimeout = 10 * time.Minute
+	}
+	return g
+}
+
+// Check verifies the circuit is not open and takes a token from the user's bucket.
+func (g *MemoryGuardrails) Check(ctx context.Context, userID string) (*GuardrailResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	g.sweepUnlocked(now)
+	u := g.userUnlocked(userID, now)
+	g.refillUnlocked(u, now)
+
+	// Circuit breaker
+	if u.state == CircuitOpen {
+		reopenAt := u.openedAt.Add(g.cfg.OpenDuration)
+		if now.Before(reopenAt) {
+			return &GuardrailResult{
+				Allowed:           false,
+				Warning:           "temporarily unavailable after repeated failures",
+				CircuitState:      CircuitOpen,
+				RemainingRequests: int(u.tokens),
+				RetryAfter:        reopenAt.Unix(),
+			}, nil
+		}
+		u.state = CircuitHalfOpen
+		u.trials = 0
+	}
+	if u.state == CircuitHalfOpen && u.trials >= g.cfg.HalfOpenRequests {
+		retryAt := u.trialAt.Add(g.cfg.OpenDuration)
+		if now.Before(retryAt) {
+			return &GuardrailResult{
+				Allowed:           false,
+				Warning:           "temporarily unavailable while recovering from failures",
+				CircuitState:      CircuitHalfOpen,
+				RemainingRequests: int(u.tokens),
+				RetryAfter:        retryAt.Unix(),
+			}, nil
+		}
+		// The trials ended without recording success or failure, for
+		// example because they were interrupted; allow new ones
+		u.trials = 0
+	}
+
+	// Rate limiter
+	if u.tokens < 1 {
+		wait := time.Minute
+		if rate := g.ratePerSecond(); rate > 0 {
+			wait = time.Duration((1 - u.tokens) / rate * float64(time.Second))
+		}
+		return &GuardrailResult{
+			Allowed:           false,
+			Warning:           fmt.Sprintf("rate limit exceeded (%d requests per minute)", int(g.cfg.RequestsPerMinute)),
+			CircuitState:      u.state,
+			RemainingRequests: 0,
+			RetryAfter:        int64(math.Ceil(float64(now.Add(wait).UnixMilli()) / 1000)),
+		}, nil
+	}
+
+	u.tokens--
+	if u.state == CircuitHalfOpen {
+		u.trials++
+		u.trialAt = now
+	}
+
+	result := &GuardrailResult{
+		Allowed:           true,
+		CircuitState:      u.state,
+		RemainingRequests: int(u.tokens),
+	}
+	if result.RemainingRequests <= g.cfg.WarningThreshold {
+		result.Warning = "approaching rate limit"
+	}
+	return result, nil
+}
+
+// RecordSuccess closes a half-open circuit once a trial run succeeds and
+// clears the user's failure counts. It has no effect on a closed or open
+// circuit, so a run that started before the circuit opened cannot close it.
+func (g *MemoryGuardrails) RecordSuccess(ctx context.Context, userID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	u := g.userUnlocked(userID, time.Now())
+	if u.state != CircuitHalfOpen || u.trials == 0 {
+		return
+	}
+	u.state = CircuitClosed
+	u.failures = nil
+	u.trials = 0
+}
+
+// RecordFailure counts a failure outside tools, such as a Claude API error.
+func (g *MemoryGuardrails) RecordFailure(ctx context.Context, userID string) {
+	g.RecordToolFailure(ctx, userID, runFailures)
+}
+
+// RecordToolSuccess resets the tool's consecutive failure count.
+func (g *MemoryGuardrails) RecordToolSuccess(ctx context.Context, userID, toolName string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	u := g.userUnlocked(userID, time.Now())
+	delete(u.failures, toolName)
+}
+
+// RecordToolFailure counts a failure of the tool and opens the circuit once
+// the tool reaches the threshold. Any failure while half-open reopens the
+// circuit.
+func (g *MemoryGuardrails) RecordToolFailure(ctx context.Context, userID, toolName string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	u := g.userUnlocked(userID, now)
+	if u.failures == nil {
+		u.failures = make(map[string]int)
+	}
+	u.failures[toolName]++
+	if u.state == CircuitHalfOpen || (u.state == CircuitClosed && u.failures[toolName] >= g.cfg.FailureThreshold) {
+		u.state = CircuitOpen
+		u.openedAt = now
+		u.trials = 0
+	}
+}
+
+// userUnlocked returns the state for a user, creating it with a full bucket.
+// Caller must hold the lock.
+func (g *MemoryGuardrails) userUnlocked(userID string, now time.Time) *userGuardrails {
+	u, ok := g.users[userID]
+	if !ok {
+		u = &userGuardrails{
+			tokens:     float64(g.cfg.Burst),
+			refilledAt: now,
+			state:      CircuitClosed,
+		}
+		g.users[userID] = u
+	}
+	u.seenAt = now
+	return u
+}
+
+// sweepUnlocked evicts users idle for IdleTimeout whose circuit is closed
+// and whose bucket has refilled, at most once per IdleTimeout. Caller must
+// hold the lock.
+func (g *MemoryGuardrails) sweepUnlocked(now time.Time) {
+	if now.Sub(g.sweptAt) < g.cfg.IdleTimeout {
+		return
+	}
+	g.sweptAt = now
+	for userID, u := range g.users {
+		if u.state != CircuitClosed || now.Sub(u.seenAt) < g.cfg.IdleTimeout {
+			continue
+		}
+		g.refillUnlocked(u, now)
+		if u.tokens >= float64(g.cfg.Burst) {
+			delete(g.users, userID)
+		}
+	}
+}
+
+// refillUnlocked adds tokens for the time elapsed since the last refill.
+// Caller must hold the lock.
+func (g *MemoryGuardrails) refillUnlocked(u *userGuardrails, now time.Time) {
+	elapsed := now.Sub(u.refilledAt).Seconds()
+	u.tokens = math.Min(float64(g.cfg.Burst), u.tokens+elapsed*g.ratePerSecond())
+	u.refilledAt = now
+}
+
+func (g *MemoryGuardrails) ratePerSecond() float64 {
+	return g.cfg.RequestsPerMinute / 60
+}
+
+// Verify MemoryGuardrails implements Guardrails.
+var (
+	_ Guardrails     = (*MemoryGuardrails)(nil)
+	_ ToolGuardrails = (*MemoryGuardrails)(nil)
+)
@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// Hooks observes the engine lifecycle, for tracing and metrics.
+// Every callback receives the run's core.Context, which may be nil when the
+// caller did not provide one. Tool callbacks are invoked concurrently when
+// several read-only tools run in one turn, so implementations must be safe
+// for concurrent use. Embed NoOpHooks to implement only some callbacks.
+type Hooks interface {
+	// OnRunStart is called when Run starts. The returned context is used for
+	// the rest of the run, so it can carry a span.
+	OnRunStart(ctx context.Context, agentCtx *core.Context, run *RunInfo) context.Context
+
+	// OnTurn is called after each Claude API call, successful or not.
+	OnTurn(ctx context.Context, agentCtx *core.Context, turn *TurnInfo)
+
+	// OnToolStart is called before a tool executes. The returned context is
+	// passed to the tool and to OnToolEnd.
+	OnToolStart(ctx context.Context, agentCtx *core.Context, tool *ToolInfo) context.Context
+
+	// OnToolEnd is called after a tool executes, with the result fields set.
+	OnToolEnd(ctx context.Context, agentCtx *core.Context, tool *ToolInfo)
+
+	// OnConfirmationNeeded is called when a run pauses for the user to
+	// confirm one or more write operations.
+	OnConfirmationNeeded(ctx context.Context, agentCtx *core.Context, actions []*core.PendingAction)
+
+	// OnRunEnd is called when Run returns.
+	OnRunEnd(ctx context.Context, agentCtx *core.Context, run *RunInfo)
+}
+
+// RunInfo describes an agent run.
+type RunInfo struct {
+	// AgentName identifies the agent being run.
+	AgentName string
+
+	// Resumed is true when the run continues after a confirmation pause.
+	Resumed bool
+
+	// Start is when the run started.
+	Start time.Time
+
+	// Duration is the total run time. Set for OnRunEnd.
+	Duration time.Duration
+
+	// Output is the run's output. Set for OnRunEnd.
+	Output *Output
+
+	// Error is the error returned by Run, if any. Set for OnRunEnd.
+	Error error
+}
+
+// TurnInfo describes a single Claude API call.
+type TurnInfo struct {
+	// Turn is the 1-based turn number within the run.
+	Turn int
+
+	// Model is the Claude model called.
+	Model string
+
+	// Start is when the request was sent.
+	Start time.Time
+
+	// Latency is the time until the full response was received.
+	Latency time.Duration
+
+	// TokensUsed is the token usage of this turn.
+	TokensUsed core.TokenUsage
+
+	// StopReason is why Claude stopped generating.
+	StopReason string
+
+	// Error is the API error, if the call failed.
+	Error error
+}
+
+// ToolInfo describes a single tool execution.
+type ToolInfo struct {
+	// ToolUseID is Claude's tool_use block ID. Empty for confirmed actions
+	// executed through ExecuteAction.
+	ToolUseID string
+
+	// Name is the tool name.
+	Name string
+
+	// Input is the tool input as JSON.
+	Input json.RawMessage
+
+	// IsWrite is true for tools that require confirmation.
+	IsWrite bool
+
+	// Start is when execution started.
+	Start time.Time
+
+	// Duration is the execution time. Set for OnToolEnd.
+	Duration time.Duration
+
+	// Result is the tool result. Set for OnToolEnd.
+	Result *core.ToolResult
+
+	// Error is the execution error, if any. Set for OnToolEnd.
+	Error error
+}
+
+// newTurnInfo builds the TurnInfo for a Claude API call.
+func newTurnInfo(turn int, model string, start time.Time, resp *anthropic.Message, err error) *TurnInfo {
+	info := &TurnInfo{
+		Turn:    turn,
+		Model:   model,
+		Start:   start,
+		Latency: time.Since(start),
+		Error:   err,
+	}
+	if resp != nil {
+		info.StopReason = string(resp.StopReason)
+		info.TokensUsed = core.TokenUsage{
+			InputTokens:              int(resp.Usage.InputTokens),
+			OutputTokens:             int(resp.Usage.OutputTokens),
+			CacheCreationInputTokens: int(resp.Usage.CacheCreationInputTokens),
+			CacheReadInputTokens:     int(resp.Usage.CacheReadInputTokens),
+		}
+	}
+	return info
+}
+
+// NoOpHooks implements Hooks with callbacks that do nothing.
+// Embed it to implement only the callbacks you need.
+type NoOpHooks struct{}
+
+// OnRunStart returns ctx unchanged.
+func (NoOpHooks) OnRunStart(ctx context.Context, agentCtx *core.Context, run *RunInfo) context.Context {
+	return ctx
+}
+
+// OnTurn does nothing.
+func (NoOpHooks) OnTurn(ctx context.Context, agentCtx *core.Context, turn *TurnInfo) {}
+
+// OnToolStart returns ctx unchanged.
+func (NoOpHooks) OnToolStart(ctx context.Context, agentCtx *core.Context, tool *ToolInfo) context.Context {
+	return ctx
+}
+
+// OnToolEnd does nothing.
+func (NoOpHooks) OnToolEnd(ctx context.Context, agentCtx *core.Context, tool *ToolInfo) {}
+
+// OnConfirmationNeeded does nothing.
+func (NoOpHooks) OnConfirmationNeeded(ctx context.Context, agentCtx *core.Context, actions []*core.PendingAction) {
+}
+
+// OnRunEnd does nothing.
+func (NoOpHooks) OnRunEnd(ctx context.Context, agentCtx *core.Context, run *RunInfo) {}
+
+// Verify NoOpHooks implements Hooks.
+var _ Hooks = NoOpHooks{}
+
+// hookList fans callbacks out to every registered Hooks in order.
+type hookList []Hooks
+
+func (h hookList) runStart(ctx context.Context, agentCtx *core.Context, run *RunInfo) context.Context {
+	for _, hooks := range h {
+		ctx = hooks.OnRunStart(ctx, agentCtx, run)
+	}
+	return ctx
+}
+
+func (h hookList) turn(ctx context.Context, agentCtx *core.Context, turn *TurnInfo) {
+	for _, hooks := range h {
+		hooks.OnTurn(ctx, agentCtx, turn)
+	}
+}
+
+func (h hookList) toolStart(ctx context.Context, agentCtx *core.Context, tool *ToolInfo) context.Context {
+	for _, hooks := range h {
+		ctx = hooks.OnToolStart(ctx, agentCtx, tool)
+	}
+	return ctx
+}
+
+func (h hookList) toolEnd(ctx context.Context, agentCtx *core.Context, tool *ToolInfo) {
+	for _, hooks := range h {
+		hooks.OnToolEnd(ctx, agentCtx, tool)
+	}
+}
+
+func (h hookList) confirmationNeeded(ctx context.Context, agentCtx *core.Context, actions []*core.PendingAction) {
+	for _, hooks := range h {
+		hooks.OnConfirmationNeeded(ctx, agentCtx, actions)
+	}
+}
+
+func (h hookList) runEnd(ctx context.Context, agentCtx *core.Context, run *RunInfo) {
+	for _, hooks := range h {
+		hooks.OnRunEnd(ctx, agentCtx, run)
+	}
+}
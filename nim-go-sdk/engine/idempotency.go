@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
 )
 
 // IdempotencyBucketDuration is the time window for idempotency key generation.
@@ -16,7 +18,8 @@ const IdempotencyBucketDuration = 10 * time.Minute
 // GenerateIdempotencyKey creates a unique key for deduplicating confirmations.
 // Keys are deterministic based on userID, tool name, canonicalized input, and
 // a 10-minute time bucket. This prevents duplicate confirmations for the same
-// action within a short time window.
+// action within a short time window. Identical actions share the key, so it
+// must not be used to deduplicate executions.
 func GenerateIdempotencyKey(userID, tool string, input json.RawMessage) string {
 	// Time bucket (10-minute windows)
 	bucket := time.Now().Unix() / int64(IdempotencyBucketDuration.Seconds())
@@ -50,3 +53,13 @@ func GenerateIdempotencyKeyWithTime(userID, tool string, input json.RawMessage,
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
+
+// hasIdempotencyKey reports whether any of the actions has the given key.
+func hasIdempotencyKey(actions []*core.PendingAction, key string) bool {
+	for _, action := range actions {
+		if action.IdempotencyKey == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// ErrStopped is the cancellation cause for a run the user stopped. Cancel
+// the run's context with context.WithCancelCause and this error so the
+// audit log records why the run ended.
+var ErrStopped = errors.New("stopped by user")
+
+// interrupted reports whether the run's context was cancelled, as opposed
+// to timing out.
+func interrupted(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// interruptedOutput ends a cancelled run, keeping the text streamed so far
+// and auditing the cancellation.
+func (e *Engine) interruptedOutput(ctx context.Context, scope auditScope, text string, toolsUsed []core.ToolExecution, tokens core.TokenUsage) *Output {
+	cause := context.Cause(ctx)
+	e.logAudit(ctx, scope, &AuditEntry{
+		EventType: AuditEventRunInterrupted,
+		Detail:    cause.Error(),
+		Timestamp: time.Now().Unix(),
+	})
+	return &Output{
+		Type:       OutputInterrupted,
+		Text:       text,
+		ToolsUsed:  toolsUsed,
+		TokensUsed: tokens,
+		Error:      cause,
+	}
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// toolQuota counts tool calls across the turns of a request and enforces
+// ExecutionLimits.MaxToolCalls and ExecutionLimits.ToolQuotas. A request
+// resumed after a confirmation pause starts from the calls it already made.
+type toolQuota struct {
+	maxCalls int
+	perTool  map[string]int
+	total    int
+	byTool   map[string]int
+}
+
+// newToolQuota creates a quota tracker from execution limits, counting the
+// prior calls as already made. A nil limits value imposes no limits.
+func newToolQuota(limits *core.ExecutionLimits, prior map[string]int) *toolQuota {
+	q := &toolQuota{byTool: make(map[string]int, len(prior))}
+	for name, n := range prior {
+		q.byTool[name] = n
+		q.total += n
+	}
+	if limits != nil {
+		q.maxCalls = limits.MaxToolCalls
+		q.perTool = limits.ToolQuotas
+	}
+	return q
+}
+
+// take records a call to the named tool. If a limit has been reached the call
+// is not recorded and a message explaining the rejection is returned.
+func (q *toolQuota) take(name string) (string, bool) {
+	if q.maxCalls > 0 && q.total >= q.maxCalls {
+		return fmt.Sprintf("error: tool call limit reached (%d calls per request). Do not call any more tools; answer with the information you already have.", q.maxCalls), false
+	}
+	if limit, ok := q.perTool[name]; ok && q.byTool[name] >= limit {
+		return fmt.Sprintf("error: call quota for %s reached (%d calls per request). Do not call %s again; answer with the information you already have.", name, limit, name), false
+	}
+
+	q.total++
+	q.byTool[name]++
+	return "", true
+}
+
+// calls returns a copy of the call counts by tool name.
+func (q *toolQuota) calls() map[string]int {
+	calls := make(map[string]int, len(q.byTool))
+	for name, n := range q.byTool {
+		calls[name] = n
+	}
+	return calls
+}
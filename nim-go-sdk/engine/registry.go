@@ -2,16 +2,19 @@
 package engine
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
 	"github.com/becomeliminal/nim-go-sdk/core"
 )
 
 // ToolRegistry manages available tools for an agent.
 type ToolRegistry struct {
-	mu    sync.RWMutex
-	tools map[string]core.Tool
+	mu         sync.RWMutex
+	tools      map[string]core.Tool
+	middleware []core.ToolMiddleware
 }
 
 // NewToolRegistry creates a new tool registry.
@@ -35,89 +38,114 @@ func (r *ToolRegistry) RegisterAll(tools ...core.Tool) {
 	}
 }
 
-// Get retrieves a tool by name.
+// Use adds middleware applied to every tool in the registry, including
+// tools registered later. Middleware added first is the outermost.
+func (r *ToolRegistry) Use(middleware ...core.ToolMiddleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Get retrieves a tool by name, wrapped with the registry's middleware.
 func (r *ToolRegistry) Get(name string) (core.Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	tool, ok := r.tools[name]
-	return tool, ok
+	if !ok {
+		return nil, false
+	}
+	return core.WrapTool(tool, r.middleware...), true
 }
 
-// List returns all registered tool names.
+// List returns all registered tool names in sorted order.
 func (r *ToolRegistry) List() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	return r.sortedNamesUnlocked()
+}
+
+// sortedNamesUnlocked returns tool names in a stable order.
+// A stable order keeps the tool definitions byte-identical between requests,
+// which prompt caching relies on. Caller must hold the lock.
+func (r *ToolRegistry) sortedNamesUnlocked() []string {
 	names := make([]string, 0, len(r.tools))
 	for name := range r.tools {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
 // ToAPITools converts registered tools to Claude API format.
+// Tools are returned sorted by name.
 func (r *ToolRegistry) ToAPITools() []anthropic.ToolUnionParam {
+	return r.ToAPIToolsFiltered(func(core.Tool) bool { return true })
+}
+
+// ToAPIToolsFiltered returns tools matching the filter, sorted by name.
+func (r *ToolRegistry) ToAPIToolsFiltered(filter func(core.Tool) bool) []anthropic.ToolUnionParam {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	tools := make([]anthropic.ToolUnionParam, 0, len(r.tools))
-	for _, tool := range r.tools {
-		schema := tool.Schema()
-		properties, _ := schema["properties"].(map[string]interface{})
-		required := []string{}
-		if reqField, ok := schema["required"].([]interface{}); ok {
-			for _, r := range reqField {
-				if str, ok := r.(string); ok {
-					required = append(required, str)
-				}
-			}
+	for _, name := range r.sortedNamesUnlocked() {
+		tool := r.tools[name]
+		if filter(tool) {
+			tools = append(tools, toAPITool(tool))
 		}
-
-		tools = append(tools, anthropic.ToolUnionParam{
-			OfTool: &anthropic.ToolParam{
-				Name:        tool.Name(),
-				Description: anthropic.String(tool.Description()),
-				InputSchema: anthropic.ToolInputSchemaParam{
-					Properties: properties,
-					Required:   required,
-				},
-			},
-		})
 	}
 	return tools
 }
 
-// ToAPIToolsFiltered returns tools matching the filter.
-func (r *ToolRegistry) ToAPIToolsFiltered(filter func(core.Tool) bool) []anthropic.ToolUnionParam {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// toAPITool converts a tool to Claude API format.
+func toAPITool(tool core.Tool) anthropic.ToolUnionParam {
+	return anthropic.ToolUnionParam{
+		OfTool: &anthropic.ToolParam{
+			Name:        tool.Name(),
+			Description: anthropic.String(tool.Description()),
+			InputSchema: toInputSchema(tool.Schema()),
+		},
+	}
+}
 
-	var tools []anthropic.ToolUnionParam
-	for _, tool := range r.tools {
-		if filter(tool) {
-			schema := tool.Schema()
-			properties, _ := schema["properties"].(map[string]interface{})
-			required := []string{}
-			if reqField, ok := schema["required"].([]interface{}); ok {
-				for _, r := range reqField {
-					if str, ok := r.(string); ok {
-						required = append(required, str)
-					}
-				}
-			}
+// toInputSchema converts a tool's JSON Schema to the API input schema.
+// The schema is sent exactly as Schema() returns it, including keywords such
+// as additionalProperties or $defs that have no dedicated field; only a
+// missing type is filled in as "object", which the API requires. It is
+// marshalled as a map, so keys are sorted and the tool definitions stay
+// byte-identical between requests for prompt caching.
+func toInputSchema(schema map[string]interface{}) anthropic.ToolInputSchemaParam {
+	wire := make(map[string]interface{}, len(schema)+1)
+	for key, value := range schema {
+		wire[key] = value
+	}
+	if _, ok := wire["type"]; !ok {
+		wire["type"] = "object"
+	}
+
+	inputSchema := param.Override[anthropic.ToolInputSchemaParam](wire)
+	inputSchema.Properties = schema["properties"]
+	inputSchema.Required = requiredFields(schema["required"])
+	return inputSchema
+}
 
-			tools = append(tools, anthropic.ToolUnionParam{
-				OfTool: &anthropic.ToolParam{
-					Name:        tool.Name(),
-					Description: anthropic.String(tool.Description()),
-					InputSchema: anthropic.ToolInputSchemaParam{
-						Properties: properties,
-						Required:   required,
-					},
-				},
-			})
+// requiredFields reads a required list, which is a []string for schemas
+// built with the tools helpers and a []interface{} for schemas decoded
+// from JSON.
+func requiredFields(value interface{}) []string {
+	switch fields := value.(type) {
+	case []string:
+		return fields
+	case []interface{}:
+		required := make([]string, 0, len(fields))
+		for _, field := range fields {
+			if str, ok := field.(string); ok {
+				required = append(required, str)
+			}
 		}
+		return required
 	}
-	return tools
+	return nil
 }
 
 // FilterByNames returns a filter that matches tools by name.
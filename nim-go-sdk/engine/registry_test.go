@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// wireSchema returns the input_schema the API receives for a tool with the
+// given schema.
+func wireSchema(t *testing.T, schema map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	registry := NewToolRegistry()
+	registry.Register(core.NewBaseTool(core.ToolDefinition{
+		ToolName:        "test_tool",
+		ToolDescription: "A test tool",
+		InputSchema:     schema,
+	}, nil))
+
+	b, err := json.Marshal(registry.ToAPITools())
+	if err != nil {
+		t.Fatalf("marshal tools: %v", err)
+	}
+	var tools []struct {
+		InputSchema map[string]interface{} `json:"input_schema"`
+	}
+	if err := json.Unmarshal(b, &tools); err != nil {
+		t.Fatalf("unmarshal tools: %v", err)
+	}
+	if len(tools) != 1 {
+		t.Fatalf("got %d tools, want 1", len(tools))
+	}
+	return tools[0].InputSchema
+}
+
+// normalize round-trips v through JSON, as the API would see it.
+func normalize(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return out
+}
+
+// decodeSchema decodes a schema from JSON, as tools loaded from files do.
+func decodeSchema(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &schema); err != nil {
+		t.Fatalf("decode schema: %v", err)
+	}
+	return schema
+}
+
+func TestToInputSchemaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema map[string]interface{}
+	}{
+		{
+			name: "required as []string",
+			schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"amount": map[string]interface{}{"type": "string"},
+					"note":   map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"amount"},
+			},
+		},
+		{
+			name: "required as []interface{}",
+			schema: decodeSchema(t, `{
+				"type": "object",
+				"properties": {"recipient": {"type": "string"}, "amount": {"type": "string"}},
+				"required": ["recipient", "amount"]
+			}`),
+		},
+		{
+			name: "additionalProperties",
+			schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"limit": map[string]interface{}{"type": "integer", "minimum": 1},
+				},
+				"additionalProperties": false,
+			},
+		},
+		{
+			name: "nested $defs",
+			schema: decodeSchema(t, `{
+				"type": "object",
+				"properties": {
+					"payee": {"$ref": "#/$defs/payee"},
+					"items": {"type": "array", "items": {"$ref": "#/$defs/item"}}
+				},
+				"required": ["payee"],
+				"$defs": {
+					"payee": {
+						"type": "object",
+						"properties": {"tag": {"type": "string"}, "address": {"$ref": "#/$defs/address"}},
+						"required": ["tag"]
+					},
+					"address": {"type": "string", "pattern": "^0x[0-9a-fA-F]{40}$"},
+					"item": {"type": "object", "properties": {"name": {"type": "string"}}, "additionalProperties": false}
+				}
+			}`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wireSchema(t, tt.schema)
+			want := normalize(t, tt.schema)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("wire schema differs from Schema()\n got: %v\nwant: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestToInputSchemaMissingType(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"query"},
+	}
+
+	got := wireSchema(t, schema)
+	if got["type"] != "object" {
+		t.Errorf("type = %v, want object", got["type"])
+	}
+
+	delete(got, "type")
+	if want := normalize(t, schema); !reflect.DeepEqual(got, want) {
+		t.Errorf("wire schema differs from Schema()\n got: %v\nwant: %v", got, want)
+	}
+	if _, ok := schema["type"]; ok {
+		t.Error("Schema() map was modified")
+	}
+}
+
+func TestToAPIToolsStableOrdering(t *testing.T) {
+	registry := NewToolRegistry()
+	for _, name := range []string{"send_money", "get_balance", "search_users", "deposit_savings"} {
+		registry.Register(core.NewBaseTool(core.ToolDefinition{
+			ToolName:        name,
+			ToolDescription: "Tool " + name,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"z": map[string]interface{}{"type": "string"},
+					"a": map[string]interface{}{"type": "number"},
+					"m": map[string]interface{}{"type": "boolean"},
+				},
+				"required":             []string{"z", "a"},
+				"additionalProperties": false,
+			},
+		}, nil))
+	}
+
+	first, err := json.Marshal(registry.ToAPITools())
+	if err != nil {
+		t.Fatalf("marshal tools: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		next, err := json.Marshal(registry.ToAPITools())
+		if err != nil {
+			t.Fatalf("marshal tools: %v", err)
+		}
+		if !bytes.Equal(first, next) {
+			t.Fatalf("tool definitions changed between calls\nfirst: %s\n next: %s", first, next)
+		}
+	}
+
+	var tools []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(first, &tools); err != nil {
+		t.Fatalf("unmarshal tools: %v", err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	want := []string{"deposit_savings", "get_balance", "search_users", "send_money"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("tool order = %v, want %v", names, want)
+	}
+}
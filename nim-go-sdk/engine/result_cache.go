@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/dgraph-io/ristretto"
+)
+
+// Values of the "cache" key in ToolResult.Metadata for cacheable tools.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// ResultCacheConfig configures a ResultCache.
+type ResultCacheConfig struct {
+	// NumCounters is the number of keys to track frequency of (10x expected items).
+	NumCounters int64
+	// MaxCost is the maximum number of cached results.
+	MaxCost int64
+	// BufferItems is the number of keys per Get buffer.
+	BufferItems int64
+
+	// TTLs is how long each tool's results are cached, by tool name.
+	TTLs map[string]time.Duration
+
+	// DefaultTTL applies to read-only tools without an entry in TTLs.
+	// Zero caches only the tools listed in TTLs.
+	DefaultTTL time.Duration
+}
+
+// DefaultResultCacheConfig returns sensible defaults for a result cache.
+// No tools are cached until TTLs or DefaultTTL are set.
+func DefaultResultCacheConfig() *ResultCacheConfig {
+	return &ResultCacheConfig{
+		NumCounters: 1e5,    // 100K counters
+		MaxCost:     10_000, // 10K results
+		BufferItems: 64,     // 64 keys per buffer
+		TTLs:        make(map[string]time.Duration),
+	}
+}
+
+// ResultCache caches successful results of read-only tools, keyed by user,
+// tool name and canonicalised input. Tools that require confirmation are
+// never cached. Safe for concurrent use.
+type ResultCache struct {
+	cache      *ristretto.Cache
+	ttls       map[string]time.Duration
+	defaultTTL time.Duration
+}
+
+// NewResultCache creates a result cache.
+func NewResultCache(cfg *ResultCacheConfig) (*ResultCache, error) {
+	if cfg == nil {
+		cfg = DefaultResultCacheConfig()
+	}
+
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: cfg.NumCounters,
+		MaxCost:     cfg.MaxCost,
+		BufferItems: cfg.BufferItems,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create result cache: %w", err)
+	}
+
+	ttls := make(map[string]time.Duration, len(cfg.TTLs))
+	for name, ttl := range cfg.TTLs {
+		ttls[name] = ttl
+	}
+
+	return &ResultCache{
+		cache:      cache,
+		ttls:       ttls,
+		defaultTTL: cfg.DefaultTTL,
+	}, nil
+}
+
+// Execute runs the tool, serving its result from the cache when possible.
+// Results of cacheable tools carry Metadata["cache"] set to CacheHit or
+// CacheMiss. Other tools are executed directly.
+func (c *ResultCache) Execute(ctx context.Context, tool core.Tool, params *core.ToolParams) (*core.ToolResult, error) {
+	ttl := c.ttlFor(tool)
+	if ttl <= 0 {
+		return tool.Execute(ctx, params)
+	}
+
+	key, err := resultCacheKey(params.UserID, tool.Name(), params.Input)
+	if err != nil {
+		// Input that cannot be canonicalised is left for the tool to reject
+		return tool.Execute(ctx, params)
+	}
+
+	if val, found := c.cache.Get(key); found {
+		return withCacheStatus(val.(*core.ToolResult), CacheHit), nil
+	}
+
+	result, err := tool.Execute(ctx, params)
+	if err != nil || result == nil || !result.Success {
+		return result, err
+	}
+
+	c.cache.SetWithTTL(key, result, 1, ttl)
+	c.cache.Wait()
+	return withCacheStatus(result, CacheMiss), nil
+}
+
+// Clear removes all cached results.
+func (c *ResultCache) Clear() {
+	c.cache.Clear()
+}
+
+// Close releases resources used by the cache.
+func (c *ResultCache) Close() {
+	c.cache.Close()
+}
+
+// ttlFor returns how long the tool's results are cached; zero if never.
+func (c *ResultCache) ttlFor(tool core.Tool) time.Duration {
+	if tool.RequiresConfirmation() {
+		return 0
+	}
+	if ttl, ok := c.ttls[tool.Name()]; ok {
+		return ttl
+	}
+	return c.defaultTTL
+}
+
+// resultCacheKey builds the cache key from the user, tool and input. The
+// input is canonicalised so that key order and whitespace do not matter.
+func resultCacheKey(userID, toolName string, input json.RawMessage) (string, error) {
+	canonical := []byte("null")
+	if len(bytes.TrimSpace(input)) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(input))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return "", err
+		}
+		var err error
+		if canonical, err = json.Marshal(value); err != nil {
+			return "", err
+		}
+	}
+
+	sum := sha256.Sum256(canonical)
+	return userID + ":" + toolName + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// withCacheStatus returns a copy of result with the cache status in its
+// metadata, leaving the cached value untouched.
+func withCacheStatus(result *core.ToolResult, status string) *core.ToolResult {
+	copied := *result
+	copied.Metadata = make(map[string]interface{}, len(result.Metadata)+1)
+	for k, v := range result.Metadata {
+		copied.Metadata[k] = v
+	}
+	copied.Metadata["cache"] = status
+	return &copied
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"time"
 
@@ -116,6 +117,20 @@ func convertCoreBlocksToAPI(blocks []core.ContentBlock) []anthropic.ContentBlock
 				}
 				result = append(result, anthropic.NewToolUseBlock(block.ToolUse.ID, inputData, block.ToolUse.Name))
 			}
+		case core.ThinkingBlockType:
+			if block.Thinking != nil {
+				result = append(result, anthropic.NewThinkingBlock(block.Thinking.Signature, block.Thinking.Thinking))
+			}
+		case core.RedactedThinkingBlockType:
+			if block.Thinking != nil {
+				result = append(result, anthropic.NewRedactedThinkingBlock(block.Thinking.Data))
+			}
+		case core.ImageBlockType, core.DocumentBlockType:
+			if block.Attachment != nil {
+				if apiBlock, ok := attachmentToAPI(block.Attachment); ok {
+					result = append(result, apiBlock)
+				}
+			}
 		case core.ToolResultBlockType:
 			if block.ToolResult != nil {
 				content := block.ToolResult.Content
@@ -128,3 +143,32 @@ func convertCoreBlocksToAPI(blocks []core.ContentBlock) []anthropic.ContentBlock
 	}
 	return result
 }
+
+// attachmentToAPI converts an image or document attachment to an API
+// content block. Reports false for media types Claude does not accept.
+func attachmentToAPI(a *core.AttachmentContent) (anthropic.ContentBlockParamUnion, bool) {
+	var block anthropic.ContentBlockParamUnion
+	switch {
+	case a.IsImage() && a.URL != "":
+		return anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: a.URL}), true
+	case a.IsImage():
+		return anthropic.NewImageBlockBase64(a.MediaType, a.Data), true
+	case a.MediaType == core.MediaTypePDF && a.URL != "":
+		block = anthropic.NewDocumentBlock(anthropic.URLPDFSourceParam{URL: a.URL})
+	case a.MediaType == core.MediaTypePDF:
+		block = anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: a.Data})
+	case a.MediaType == core.MediaTypeText:
+		// Claude cannot fetch plain text from a URL
+		text, err := base64.StdEncoding.DecodeString(a.Data)
+		if err != nil || a.Data == "" {
+			return block, false
+		}
+		block = anthropic.NewDocumentBlock(anthropic.PlainTextSourceParam{Data: string(text)})
+	default:
+		return block, false
+	}
+	if a.Name != "" {
+		block.OfDocument.Title = anthropic.String(a.Name)
+	}
+	return block, true
+}
@@ -0,0 +1,302 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// DefaultToolConcurrency is the default maximum number of read-only tools
+// executed concurrently within a single turn.
+const DefaultToolConcurrency = 4
+
+// errNotExecuted answers calls skipped because the run was cancelled.
+const errNotExecuted = "error: not executed, the run was cancelled"
+
+// toolCall tracks a single tool_use block through planning and execution.
+type toolCall struct {
+	id    string
+	name  string
+	input json.RawMessage
+	tool  core.Tool
+
+	// rejection is set when the call is answered without executing the tool.
+	rejection string
+
+	// details describes the action for confirmation policies and the
+	// pending action, if the tool implements ActionDescriber.
+	details *core.ActionDetails
+
+	// write is set for writes whose confirmation a policy waived. They run
+	// sequentially after the read-only tools, as if confirmed.
+	write          bool
+	confirmationID string // also the executor's idempotency key
+	idempotencyKey string // detects the same write twice in one turn
+	reason         string
+
+	result   *core.ToolResult
+	err      error
+	start    time.Time
+	duration time.Duration
+}
+
+// executeToolCalls runs all executable calls. Read-only tools run at most
+// limit at a time; writes with waived confirmation then run one by one in
+// block order. Results are stored on each call so callers can consume them
+// in block order.
+func (e *Engine) executeToolCalls(ctx context.Context, session *Session, agentCtx *core.Context, requestID string, calls []*toolCall, limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, call := range calls {
+		if !call.executed() || call.write {
+			continue
+		}
+		if ctx.Err() != nil {
+			call.rejection = errNotExecuted
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(call *toolCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			e.runToolCall(ctx, session, agentCtx, requestID, call)
+		}(call)
+	}
+	wg.Wait()
+
+	for _, call := range calls {
+		if !call.executed() || !call.write {
+			continue
+		}
+		if ctx.Err() != nil {
+			call.rejection = errNotExecuted
+			continue
+		}
+		// A write that has started always finishes, so a stopped run never
+		// leaves a money movement in an unknown state
+		e.runToolCall(context.WithoutCancel(ctx), session, agentCtx, requestID, call)
+	}
+}
+
+// runToolCall executes a single call, surrounded by the tool hooks.
+func (e *Engine) runToolCall(ctx context.Context, session *Session, agentCtx *core.Context, requestID string, call *toolCall) {
+	call.start = time.Now()
+	info := &ToolInfo{
+		ToolUseID: call.id,
+		Name:      call.name,
+		Input:     call.input,
+		IsWrite:   call.isWrite(),
+		Start:     call.start,
+	}
+	toolCtx := e.hooks.toolStart(ctx, agentCtx, info)
+	EmitEvent(ctx, Event{
+		Type:      EventToolStart,
+		ToolUseID: call.id,
+		ToolName:  call.name,
+		Summary:   call.tool.GetSummary(call.input),
+	})
+
+	call.result, call.err = e.executeTool(toolCtx, call.tool, &core.ToolParams{
+		UserID:         session.UserID,
+		ToolName:       call.name,
+		Input:          call.input,
+		ConfirmationID: call.confirmationID,
+		IdempotencyKey: call.confirmationID,
+		RequestID:      requestID,
+	})
+	call.duration = time.Since(call.start)
+
+	info.Duration = call.duration
+	info.Result, info.Error = call.result, call.err
+	e.hooks.toolEnd(toolCtx, agentCtx, info)
+
+	end := Event{
+		Type:      EventToolEnd,
+		ToolUseID: call.id,
+		ToolName:  call.name,
+		Duration:  call.duration,
+		Success:   !call.failed(),
+	}
+	switch {
+	case call.err != nil:
+		end.Error = call.err.Error()
+	case call.result != nil && !call.result.Success:
+		end.Error = call.result.Error
+	}
+	EmitEvent(ctx, end)
+}
+
+// executeTool runs a tool, through the result cache when one is configured.
+func (e *Engine) executeTool(ctx context.Context, tool core.Tool, params *core.ToolParams) (*core.ToolResult, error) {
+	if e.cache != nil {
+		return e.cache.Execute(ctx, tool, params)
+	}
+	return tool.Execute(ctx, params)
+}
+
+// describeActions gathers the details of every call not already rejected.
+// Tools that describe their actions may make preflight reads, so they run
+// at most toolConcurrency at a time.
+func (e *Engine) describeActions(ctx context.Context, calls []*toolCall, userID, requestID string) {
+	limit := e.toolConcurrency
+	if limit < 1 {
+		limit = 1
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, call := range calls {
+		if call.rejection != "" {
+			continue
+		}
+		if _, ok := call.tool.(core.ActionDescriber); !ok {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(call *toolCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			call.details = describeAction(ctx, call.tool, userID, requestID, call.input)
+		}(call)
+	}
+	wg.Wait()
+}
+
+// describeAction returns structured details of a proposed write if the tool
+// provides them. Preflight failures are not fatal: whatever details were
+// gathered are kept and the summary remains the fallback.
+func describeAction(ctx context.Context, tool core.Tool, userID, requestID string, input json.RawMessage) *core.ActionDetails {
+	describer, ok := tool.(core.ActionDescriber)
+	if !ok {
+		return nil
+	}
+	details, _ := describer.DescribeAction(ctx, &core.ToolParams{
+		UserID:    userID,
+		ToolName:  tool.Name(),
+		Input:     input,
+		RequestID: requestID,
+	})
+	return details
+}
+
+// hasWaivedWrite reports whether a write with the given idempotency key is
+// already due to execute without confirmation in this turn.
+func hasWaivedWrite(calls []*toolCall, idempotencyKey string) bool {
+	for _, call := range calls {
+		if call.write && call.rejection == "" && call.idempotencyKey == idempotencyKey {
+			return true
+		}
+	}
+	return false
+}
+
+// isWrite reports whether the call performs a write.
+func (c *toolCall) isWrite() bool {
+	return c.write || c.tool.RequiresConfirmation()
+}
+
+// executed reports whether the tool was actually run.
+func (c *toolCall) executed() bool {
+	return c.tool != nil && c.rejection == ""
+}
+
+// failed reports whether an executed tool returned an error or no result.
+func (c *toolCall) failed() bool {
+	return c.err != nil || c.result == nil || !c.result.Success
+}
+
+// execution returns the ToolExecution record for an executed call.
+func (c *toolCall) execution() core.ToolExecution {
+	execution := core.ToolExecution{
+		Tool:       c.name,
+		Input:      c.input,
+		DurationMs: c.duration.Milliseconds(),
+	}
+	switch {
+	case c.err != nil:
+		execution.Error = c.err.Error()
+	case c.result != nil && !c.result.Success:
+		execution.Error = c.result.Error
+	case c.result != nil:
+		execution.Result = c.result.Data
+	}
+	return execution
+}
+
+// resultContent returns the tool result to send back to Claude.
+func (c *toolCall) resultContent() core.ToolResultContent {
+	result := core.ToolResultContent{ToolUseID: c.id}
+	switch {
+	case c.rejection != "":
+		result.Content, result.IsError = c.rejection, true
+	case c.err != nil:
+		result.Content, result.IsError = c.err.Error(), true
+	case c.result != nil && !c.result.Success:
+		result.Content, result.IsError = c.result.Error, true
+	default:
+		var data interface{}
+		if c.result != nil {
+			data = c.result.Data
+		}
+		resultBytes, _ := json.Marshal(data)
+		result.Content = string(resultBytes)
+	}
+	return result
+}
+
+// resultBlock returns the tool_result block to send back to Claude.
+func (c *toolCall) resultBlock() anthropic.ContentBlockParamUnion {
+	result := c.resultContent()
+	return anthropic.NewToolResultBlock(result.ToolUseID, result.Content, result.IsError)
+}
+
+// auditEntry builds the audit entry for an executed call.
+func (c *toolCall) auditEntry() *AuditEntry {
+	var outputBytes json.RawMessage
+	var errStr *string
+	if c.result != nil {
+		outputBytes, _ = json.Marshal(c.result.Data)
+		if c.result.Error != "" {
+			errStr = &c.result.Error
+		}
+	}
+	if c.err != nil {
+		errMsg := c.err.Error()
+		errStr = &errMsg
+	}
+	return &AuditEntry{
+		EventType:  AuditEventToolExecution,
+		ToolName:   c.name,
+		ToolInput:  c.input,
+		ToolOutput: outputBytes,
+		Error:      errStr,
+		DurationMs: c.duration.Milliseconds(),
+		IsWriteOp:  c.isWrite(),
+		Timestamp:  c.start.Unix(),
+	}
+}
+
+// waivedAuditEntry records that a policy waived confirmation for a write.
+func (c *toolCall) waivedAuditEntry() *AuditEntry {
+	return &AuditEntry{
+		EventType: AuditEventConfirmationWaived,
+		ActionID:  c.confirmationID,
+		ToolName:  c.name,
+		ToolInput: c.input,
+		Detail:    confirmationDetail(core.ConfirmationNone, c.reason),
+		IsWriteOp: true,
+		Timestamp: c.start.Unix(),
+	}
+}
@@ -140,6 +140,11 @@ func (e *HTTPExecutor) doRequest(ctx context.Context, method, endpoint string, b
 
 	if method != "GET" {
 		req.Header.Set("Content-Type", "application/json")
+
+		// Let the gateway deduplicate retried writes
+		if key := core.IdempotencyKeyFromContext(ctx); key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
 	}
 
 	// Prefer JWT over API key
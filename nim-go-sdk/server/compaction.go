@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/engine"
+	"github.com/becomeliminal/nim-go-sdk/store"
+)
+
+// compactHistory summarises older turns once the session history exceeds
+// the compaction budget, and persists the summary so a resumed
+// conversation starts from the compacted history. Failures leave the
+// history as it is.
+func (s *Server) compactHistory(ctx context.Context, sess *session) {
+	if s.contextManager == nil {
+		return
+	}
+
+	compaction, err := s.contextManager.Compact(ctx, sess.History)
+	if err != nil {
+		log.Printf("Failed to compact conversation %s: %v", sess.ConversationID, err)
+		return
+	}
+	if compaction == nil {
+		return
+	}
+
+	log.Printf("[CONVERSATION %s] Compacted %d messages (~%d -> ~%d tokens)",
+		sess.ConversationID, compaction.Replaced, compaction.TokensBefore, compaction.TokensAfter)
+	sess.History = compaction.History
+
+	err = s.conversations.Append(ctx, &store.AppendMessage{
+		ConversationID: sess.ConversationID,
+		Role:           string(core.RoleUser),
+		Content:        compaction.Summary,
+		Kind:           store.MessageKindSummary,
+		KeptTurns:      compaction.KeptTurns,
+	})
+	if err != nil {
+		log.Printf("Failed to persist conversation summary: %v", err)
+	}
+}
+
+// restoreHistory rebuilds a session history from stored messages.
+// Interrupted responses keep their marker, and a stored summary replaces
+// everything before the last KeptTurns user messages that precede it.
+func restoreHistory(messages []store.StoredMessage) []core.Message {
+	history := make([]core.Message, 0, len(messages))
+	for _, m := range messages {
+		switch m.Kind {
+		case store.MessageKindInterrupted:
+			history = append(history, interruptedMessage(m.Content))
+			continue
+		case store.MessageKindSummary:
+		default:
+			if len(m.Attachments) > 0 {
+				history = append(history, core.NewUserMessageWithAttachments(m.Content, m.Attachments))
+				continue
+			}
+			history = append(history, core.Message{
+				Role:    core.Role(m.Role),
+				Content: m.Content,
+			})
+			continue
+		}
+
+		kept := len(history)
+		for turns := 0; kept > 0 && turns < m.KeptTurns; {
+			kept--
+			if history[kept].Role == core.RoleUser && !engine.IsSummaryMessage(history[kept]) {
+				turns++
+			}
+		}
+		history = append([]core.Message{engine.NewSummaryMessage(m.Content)}, history[kept:]...)
+	}
+	return history
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/engine"
+	"github.com/becomeliminal/nim-go-sdk/store"
+)
+
+// cleanupInterval is how often expired actions are removed from the
+// confirmation store.
+const cleanupInterval = time.Minute
+
+// cleanupExpired removes expired actions from the confirmation store, at
+// most once per cleanupInterval across all connections, and audits each
+// one it removed.
+func (s *Server) cleanupExpired(ctx context.Context) {
+	now := time.Now().Unix()
+	last := s.lastCleanup.Load()
+	if now-last < int64(cleanupInterval/time.Second) || !s.lastCleanup.CompareAndSwap(last, now) {
+		return
+	}
+
+	cleaner, ok := s.confirmations.(store.ExpiringConfirmations)
+	if !ok {
+		if _, err := s.confirmations.Cleanup(ctx); err != nil {
+			log.Printf("Failed to clean up expired confirmations: %v", err)
+		}
+		return
+	}
+	expired, err := cleaner.CleanupExpired(ctx)
+	if err != nil {
+		log.Printf("Failed to clean up expired confirmations: %v", err)
+		return
+	}
+	for _, action := range expired {
+		s.engine.AuditConfirmation(ctx, engine.AuditEventConfirmationExpired, action, "Expired before the user approved it")
+	}
+}
+
+// expiryAudited reports whether cleanup has already audited the action's
+// expiry. Cleanup removes every action that expired before it started, so
+// only actions that expired since then still need an audit entry.
+func (s *Server) expiryAudited(action *core.PendingAction) bool {
+	if _, ok := s.confirmations.(store.ExpiringConfirmations); !ok {
+		return false
+	}
+	return action.ExpiresAt < s.lastCleanup.Load()
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/engine"
+)
+
+// executedActions remembers recently executed actions and their results so
+// a repeated confirm can be answered with the real outcome instead of
+// "expired". Confirmations.Confirm already removes an action atomically, so
+// this is only used for reporting, never to decide whether an action may run.
+type executedActions struct {
+	mu      sync.Mutex
+	actions map[string]*executedAction // actionID -> execution
+}
+
+// executedAction is an action that was confirmed and started executing.
+type executedAction struct {
+	at       time.Time
+	action   *core.PendingAction
+	result   core.ToolResultContent
+	finished bool // result is set
+}
+
+func newExecutedActions() *executedActions {
+	return &executedActions{actions: make(map[string]*executedAction)}
+}
+
+// add records an action as executing and drops entries older than the
+// idempotency window.
+func (e *executedActions) add(action *core.PendingAction) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	for id, executed := range e.actions {
+		if now.Sub(executed.at) > engine.IdempotencyBucketDuration {
+			delete(e.actions, id)
+		}
+	}
+	e.actions[action.ID] = &executedAction{at: now, action: action}
+}
+
+// finish records the result of an executing action.
+func (e *executedActions) finish(actionID string, result core.ToolResultContent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if executed, ok := e.actions[actionID]; ok {
+		executed.result = result
+		executed.finished = true
+	}
+}
+
+// get returns the action if it was executed within the idempotency window.
+func (e *executedActions) get(actionID string) (executedAction, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	executed, ok := e.actions[actionID]
+	if !ok || time.Since(executed.at) > engine.IdempotencyBucketDuration {
+		return executedAction{}, false
+	}
+	return *executed, true
+}
+
+// actionOwners tracks which session is waiting on each stored action. When
+// a duplicate request on another connection reuses an action, that session
+// becomes its owner, and the session that proposed it first may no longer
+// cancel it.
+type actionOwners struct {
+	mu     sync.Mutex
+	owners map[string]actionOwner // actionID -> owner
+}
+
+type actionOwner struct {
+	sess      *session
+	expiresAt int64
+}
+
+func newActionOwners() *actionOwners {
+	return &actionOwners{owners: make(map[string]actionOwner)}
+}
+
+// claim makes sess the owner of the action and drops expired entries.
+func (o *actionOwners) claim(action *core.PendingAction, sess *session) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now().Unix()
+	for id, owner := range o.owners {
+		if owner.expiresAt < now {
+			delete(o.owners, id)
+		}
+	}
+	o.owners[action.ID] = actionOwner{sess: sess, expiresAt: action.ExpiresAt}
+}
+
+// release gives up sess's claim on the action. It reports false if another
+// session owns the action, in which case sess must leave it stored.
+func (o *actionOwners) release(actionID string, sess *session) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	owner, ok := o.owners[actionID]
+	if ok && owner.sess != sess {
+		return false
+	}
+	delete(o.owners, actionID)
+	return true
+}
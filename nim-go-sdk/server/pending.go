@@ -0,0 +1,104 @@
+package server
+
+import (
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/engine"
+)
+
+// pendingTurn tracks an assistant turn that paused for confirmation.
+// Every tool_use block in the turn needs a tool_result before the agent can
+// resume, so results are collected until all pending actions are resolved.
+type pendingTurn struct {
+	order   []string                          // tool_use IDs in block order
+	results map[string]core.ToolResultContent // tool_use ID -> result
+	actions map[string]*core.PendingAction    // outstanding action ID -> action
+
+	requestID string         // request that paused; the resumed run continues it
+	toolCalls map[string]int // tool calls the request made before pausing
+	planID    string         // set when the turn proposed several writes
+	steps     []string       // action IDs in plan order
+}
+
+// newPendingTurn creates a pendingTurn from a confirmation-needed output.
+func newPendingTurn(output *engine.Output) *pendingTurn {
+	p := &pendingTurn{
+		results:   make(map[string]core.ToolResultContent),
+		actions:   make(map[string]*core.PendingAction),
+		toolCalls: output.ToolCalls,
+	}
+	for _, block := range output.ResponseBlocks {
+		if block.Type == core.ToolUseBlockType && block.ToolUse != nil {
+			p.order = append(p.order, block.ToolUse.ID)
+		}
+	}
+	for _, result := range output.ToolResults {
+		p.results[result.ToolUseID] = result
+	}
+	for _, action := range output.PendingActions {
+		p.actions[action.ID] = action
+		p.requestID = action.RequestID
+		if action.PlanID != "" {
+			p.planID = action.PlanID
+			p.steps = append(p.steps, action.ID)
+		}
+	}
+	return p
+}
+
+// has reports whether the action is still awaiting a decision in this turn.
+func (p *pendingTurn) has(actionID string) bool {
+	_, ok := p.actions[actionID]
+	return ok
+}
+
+// action returns an outstanding action, or nil if it is not outstanding.
+func (p *pendingTurn) action(actionID string) *core.PendingAction {
+	return p.actions[actionID]
+}
+
+// resolve records the result for an outstanding action. The result is
+// always attributed to this turn's tool_use block for the action, which may
+// differ from the stored action's BlockID when a duplicate request reused it.
+func (p *pendingTurn) resolve(actionID string, result core.ToolResultContent) {
+	if action, ok := p.actions[actionID]; ok {
+		result.ToolUseID = action.BlockID
+	}
+	p.results[result.ToolUseID] = result
+	delete(p.actions, actionID)
+}
+
+// outstandingSteps returns the plan's unresolved action IDs in plan order.
+func (p *pendingTurn) outstandingSteps() []string {
+	ids := make([]string, 0, len(p.steps))
+	for _, id := range p.steps {
+		if p.has(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// outstanding returns the IDs of actions still awaiting a decision.
+func (p *pendingTurn) outstanding() []string {
+	ids := make([]string, 0, len(p.actions))
+	for id := range p.actions {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// done reports whether every pending action has been resolved.
+func (p *pendingTurn) done() bool {
+	return len(p.actions) == 0
+}
+
+// orderedResults returns the collected results in tool_use block order.
+func (p *pendingTurn) orderedResults() []core.ToolResultContent {
+	results := make([]core.ToolResultContent, 0, len(p.results))
+	for _, id := range p.order {
+		if result, ok := p.results[id]; ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/engine"
+)
+
+// Plan step statuses reported in plan_result messages.
+const (
+	planStepExecuted = "executed"
+	planStepFailed   = "failed"
+	planStepSkipped  = "skipped"
+)
+
+// handleConfirmPlan approves every outstanding step of a plan with a single
+// confirmation and executes the steps in order. Execution stops at the first
+// failing step; later steps are cancelled and reported as skipped.
+func (s *Server) handleConfirmPlan(ctx context.Context, conn *websocket.Conn, sess *session, userID, planID, stepUpToken string) {
+	log.Printf("Processing plan confirmation for plan=%s, user=%s", planID, userID)
+
+	if sess.pending == nil || sess.pending.planID != planID {
+		s.send(conn, ServerMessage{
+			Type:    "text",
+			Content: "That plan is no longer pending. Would you like me to set it up again?",
+		})
+		s.send(conn, ServerMessage{Type: "complete"})
+		return
+	}
+
+	// Approval is all-or-nothing: make sure every step is still pending
+	// before running any of them.
+	stepIDs := sess.pending.outstandingSteps()
+	actions := make([]*core.PendingAction, 0, len(stepIDs))
+	for _, id := range stepIDs {
+		action, err := s.confirmations.Get(ctx, userID, id)
+		if err != nil {
+			s.cancelPlanSteps(ctx, sess, stepIDs, engine.AuditEventConfirmationExpired, "Not executed: the plan expired before the user approved it")
+			s.finishPending(ctx, conn, sess)
+			return
+		}
+		actions = append(actions, action)
+	}
+	if !s.verifyStepUp(ctx, conn, userID, actions, stepUpToken) {
+		return
+	}
+
+	steps := make([]PlanStep, 0, len(actions))
+	executed := 0
+	failure := ""
+	for _, action := range actions {
+		step := PlanStep{ActionID: action.ID, Tool: action.Tool, Summary: action.Summary}
+
+		// Stopping the run skips the steps that have not started
+		if failure == "" && ctx.Err() != nil {
+			failure = "the user stopped the run"
+		}
+
+		if failure != "" {
+			cancelled, err := s.cancelAction(context.WithoutCancel(ctx), sess, action.ID)
+			if err != nil {
+				log.Printf("Failed to cancel plan step %s: %v", action.ID, err)
+			}
+			step.Status = planStepSkipped
+			if cancelled {
+				s.engine.AuditConfirmation(ctx, engine.AuditEventConfirmationCancelled, action, "Not executed: "+failure)
+			}
+			sess.pending.resolve(action.ID, core.ToolResultContent{
+				ToolUseID: action.BlockID,
+				Content:   "Not executed: " + failure,
+				IsError:   true,
+			})
+			steps = append(steps, step)
+			continue
+		}
+
+		// Claim the step before running it so it can never execute twice
+		var result core.ToolResultContent
+		if _, err := s.confirmations.Confirm(ctx, userID, action.ID); err != nil {
+			result = core.ToolResultContent{
+				ToolUseID: action.BlockID,
+				Content:   fmt.Sprintf("Error: %v", err),
+				IsError:   true,
+			}
+		} else {
+			s.engine.AuditConfirmation(ctx, engine.AuditEventConfirmationConfirmed, action, "")
+			s.executed.add(action)
+			toolResult, err := s.engine.ExecuteAction(context.WithoutCancel(ctx), action)
+			result = actionResult(action, toolResult, err)
+			s.executed.finish(action.ID, result)
+		}
+		s.owners.release(action.ID, sess)
+		sess.pending.resolve(action.ID, result)
+
+		if result.IsError {
+			step.Status = planStepFailed
+			step.Error = result.Content
+			failure = fmt.Sprintf("step %d (%s) failed", action.PlanStep, action.Tool)
+		} else {
+			step.Status = planStepExecuted
+			executed++
+		}
+		steps = append(steps, step)
+	}
+
+	report := fmt.Sprintf("Completed all %d steps.", len(steps))
+	if failure != "" {
+		report = fmt.Sprintf("Completed %d of %d steps; stopped because %s.", executed, len(steps), failure)
+	}
+	s.send(conn, ServerMessage{
+		Type:    "plan_result",
+		PlanID:  planID,
+		Content: report,
+		Steps:   steps,
+		IsError: failure != "",
+	})
+
+	s.finishPending(ctx, conn, sess)
+}
+
+// handleCancelPlan rejects every outstanding step of a plan.
+func (s *Server) handleCancelPlan(ctx context.Context, conn *websocket.Conn, sess *session, userID, planID string) {
+	if sess.pending == nil || sess.pending.planID != planID {
+		s.sendError(conn, "Plan not found")
+		return
+	}
+
+	s.cancelPlanSteps(ctx, sess, sess.pending.outstandingSteps(), engine.AuditEventConfirmationCancelled, "Cancelled by user")
+	s.finishPending(ctx, conn, sess)
+}
+
+// cancelPlanSteps cancels the given steps, audits them as event and records
+// reason as their result.
+func (s *Server) cancelPlanSteps(ctx context.Context, sess *session, stepIDs []string, event, reason string) {
+	for _, id := range stepIDs {
+		cancelled, err := s.cancelAction(ctx, sess, id)
+		if err != nil {
+			log.Printf("Failed to cancel plan step %s: %v", id, err)
+		}
+		action := sess.pending.action(id)
+		if cancelled || (event == engine.AuditEventConfirmationExpired && !s.expiryAudited(action)) {
+			s.engine.AuditConfirmation(ctx, event, action, reason)
+		}
+		sess.pending.resolve(id, core.ToolResultContent{
+			Content: reason,
+			IsError: true,
+		})
+	}
+}
@@ -1,25 +1,72 @@
 // Package server provides a ready-to-run WebSocket server for the Nim agent.
 package server
 
+import "github.com/becomeliminal/nim-go-sdk/core"
+
 // ClientMessage is a message from the client.
 type ClientMessage struct {
-	Type           string `json:"type"` // "new_conversation", "resume_conversation", "message", "confirm", "cancel"
-	Content        string `json:"content,omitempty"`
-	ActionID       string `json:"actionId,omitempty"`
-	ConversationID string `json:"conversationId,omitempty"`
+	Type           string       `json:"type"` // "new_conversation", "resume_conversation", "message", "confirm", "cancel", "stop"
+	Content        string       `json:"content,omitempty"`
+	ActionID       string       `json:"actionId,omitempty"`
+	PlanID         string       `json:"planId,omitempty"` // Confirm or cancel a whole plan instead of a single action
+	ConversationID string       `json:"conversationId,omitempty"`
+	StepUpToken    string       `json:"stepUpToken,omitempty"`    // Additional verification when confirming a step-up action
+	StreamThinking bool         `json:"streamThinking,omitempty"` // Receive extended thinking as thinking_chunk messages for this conversation
+	Attachments    []Attachment `json:"attachments,omitempty"`    // Images and documents sent with a message
+}
+
+// Attachment is an image or document sent with a message, either inline as
+// base64 data or as an https URL to a file uploaded elsewhere.
+type Attachment struct {
+	MediaType string `json:"mediaType"` // "image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf" or "text/plain"
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// toCore converts the attachment to its core form.
+func (a Attachment) toCore() core.AttachmentContent {
+	return core.AttachmentContent{
+		MediaType: a.MediaType,
+		Data:      a.Data,
+		URL:       a.URL,
+		Name:      a.Name,
+	}
 }
 
 // ServerMessage is a message to the client.
 type ServerMessage struct {
-	Type           string      `json:"type"` // "conversation_started", "conversation_resumed", "text", "text_chunk", "confirm_request", "complete", "error"
-	Content        string      `json:"content,omitempty"`
-	ActionID       string      `json:"actionId,omitempty"`
-	Tool           string      `json:"tool,omitempty"`
-	Summary        string      `json:"summary,omitempty"`
-	ExpiresAt      string      `json:"expiresAt,omitempty"`
-	ConversationID string      `json:"conversationId,omitempty"`
-	Messages       interface{} `json:"messages,omitempty"`
-	TokenUsage     *TokenUsage `json:"tokenUsage,omitempty"`
+	Type           string         `json:"type"` // "conversation_started", "conversation_resumed", "text", "text_chunk", "thinking_chunk", "turn_start", "tool_start", "tool_end", "subagent_start", "subagent_end", "confirm_request", "action_result", "plan_result", "interrupted", "complete", "error"
+	Content        string         `json:"content,omitempty"`
+	ActionID       string         `json:"actionId,omitempty"`
+	Tool           string         `json:"tool,omitempty"`
+	ToolUseID      string         `json:"toolUseId,omitempty"` // Correlates tool_start and tool_end
+	Agent          string         `json:"agent,omitempty"`     // Sub-agent in subagent_start and subagent_end
+	Summary        string         `json:"summary,omitempty"`
+	Turn           int            `json:"turn,omitempty"`
+	DurationMs     int64          `json:"durationMs,omitempty"`
+	Details        *ActionDetails `json:"details,omitempty"` // Structured details of the first action in a confirm_request
+	ExpiresAt      string         `json:"expiresAt,omitempty"`
+	Actions        []Confirmation `json:"actions,omitempty"` // All pending actions in a confirm_request
+	PlanID         string         `json:"planId,omitempty"`
+	Steps          []PlanStep     `json:"steps,omitempty"` // Step outcomes in a plan_result
+	IsError        bool           `json:"isError,omitempty"`
+	Code           string         `json:"code,omitempty"`       // Machine-readable error code, e.g. "rate_limited"
+	RetryAfter     string         `json:"retryAfter,omitempty"` // When a blocked request may be retried (RFC 3339)
+	ConversationID string         `json:"conversationId,omitempty"`
+	Messages       interface{}    `json:"messages,omitempty"`
+	TokenUsage     *TokenUsage    `json:"tokenUsage,omitempty"`
+}
+
+// newTokenUsage converts engine token usage to the protocol type.
+func newTokenUsage(u core.TokenUsage) *TokenUsage {
+	return &TokenUsage{
+		InputTokens:              u.InputTokens,
+		OutputTokens:             u.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens,
+		TotalTokens:              u.TotalTokens(),
+	}
 }
 
 // TokenUsage tracks Claude API token consumption.
@@ -33,8 +80,47 @@ type TokenUsage struct {
 
 // Confirmation contains details about a pending action.
 type Confirmation struct {
-	ID        string `json:"id"`
-	Tool      string `json:"tool"`
-	Summary   string `json:"summary"`
-	ExpiresAt int64  `json:"expiresAt"`
+	ID        string         `json:"id"`
+	Tool      string         `json:"tool"`
+	Summary   string         `json:"summary"`
+	Details   *ActionDetails `json:"details,omitempty"`
+	StepUp    bool           `json:"stepUp,omitempty"` // Confirm with a stepUpToken
+	Reason    string         `json:"reason,omitempty"` // Why the confirmation policy asked for approval
+	ExpiresAt int64          `json:"expiresAt"`
+	PlanStep  int            `json:"planStep,omitempty"`
+}
+
+// ActionDetails describes a pending action in structured form, for
+// rendering a confirmation card. Fields that are not known are omitted.
+type ActionDetails struct {
+	ActionType       string `json:"actionType"` // "send", "deposit", "withdraw"
+	Amount           string `json:"amount,omitempty"`
+	Currency         string `json:"currency,omitempty"`
+	Recipient        string `json:"recipient,omitempty"`        // Display tag as given, e.g. "@alice"
+	RecipientUserID  string `json:"recipientUserId,omitempty"`  // Resolved user ID of the recipient
+	ProjectedBalance string `json:"projectedBalance,omitempty"` // Wallet balance in currency after the action
+}
+
+// newActionDetails converts core action details to the protocol type.
+func newActionDetails(d *core.ActionDetails) *ActionDetails {
+	if d == nil {
+		return nil
+	}
+	return &ActionDetails{
+		ActionType:       d.ActionType,
+		Amount:           d.Amount,
+		Currency:         d.Currency,
+		Recipient:        d.Recipient,
+		RecipientUserID:  d.RecipientUserID,
+		ProjectedBalance: d.ProjectedBalance,
+	}
+}
+
+// PlanStep reports the outcome of one step of an approved plan.
+type PlanStep struct {
+	ActionID string `json:"actionId"`
+	Tool     string `json:"tool"`
+	Summary  string `json:"summary"`
+	Status   string `json:"status"` // "executed", "failed", "skipped"
+	Error    string `json:"error,omitempty"`
 }
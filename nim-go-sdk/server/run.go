@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/engine"
+	"github.com/becomeliminal/nim-go-sdk/store"
+	"github.com/gorilla/websocket"
+)
+
+// maxQueuedMessages is how many client messages may wait while the agent
+// is busy before new ones are rejected.
+const maxQueuedMessages = 16
+
+// InterruptedMarker is appended to the history entry of a response the
+// user stopped, so Claude knows it was cut short.
+const InterruptedMarker = "[Response interrupted by the user]"
+
+// errDisconnected is the cancellation cause when the client goes away.
+var errDisconnected = errors.New("client disconnected")
+
+// runControl tracks the context of the message being processed so that it
+// can be cancelled from the read loop.
+type runControl struct {
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc
+}
+
+// start returns a context for processing the next message.
+func (c *runControl) start(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancelCause(ctx)
+	c.mu.Lock()
+	c.cancel = cancel
+	c.mu.Unlock()
+	return ctx
+}
+
+// finish releases the context of the processed message.
+func (c *runControl) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel(nil)
+		c.cancel = nil
+	}
+}
+
+// stop cancels the message being processed with the given cause. Reports
+// whether anything was running.
+func (c *runControl) stop(cause error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel == nil {
+		return false
+	}
+	c.cancel(cause)
+	return true
+}
+
+// eventMessage converts an engine progress event to a server message.
+// Thinking deltas become thinking_chunk. Text deltas are not converted, as
+// streamed text is sent as text_chunk.
+func eventMessage(event engine.Event) (ServerMessage, bool) {
+	msg := ServerMessage{
+		Type:       string(event.Type),
+		Tool:       event.ToolName,
+		ToolUseID:  event.ToolUseID,
+		Agent:      event.AgentName,
+		Summary:    event.Summary,
+		Turn:       event.Turn,
+		DurationMs: event.Duration.Milliseconds(),
+	}
+	switch event.Type {
+	case engine.EventThinkingDelta:
+		msg.Type = "thinking_chunk"
+		msg.Content = event.Text
+	case engine.EventTurnStart, engine.EventToolStart, engine.EventSubAgentStart:
+	case engine.EventToolEnd, engine.EventSubAgentEnd:
+		msg.IsError = !event.Success
+		msg.Content = event.Error
+	default:
+		return ServerMessage{}, false
+	}
+	return msg, true
+}
+
+// handleInterrupted keeps the text streamed before a run was stopped,
+// marked as interrupted, and tells the client the run has ended.
+func (s *Server) handleInterrupted(ctx context.Context, conn *websocket.Conn, sess *session, output *engine.Output) {
+	log.Printf("[CONVERSATION %s] Run interrupted: %v", sess.ConversationID, output.Error)
+
+	// The run's context is cancelled; persistence must still happen
+	ctx = context.WithoutCancel(ctx)
+
+	sess.History = append(sess.History, interruptedMessage(output.Text))
+
+	err := s.conversations.Append(ctx, &store.AppendMessage{
+		ConversationID: sess.ConversationID,
+		Role:           string(core.RoleAssistant),
+		Content:        output.Text,
+		Kind:           store.MessageKindInterrupted,
+	})
+	if err != nil {
+		log.Printf("Failed to persist interrupted message: %v", err)
+	}
+
+	s.send(conn, ServerMessage{Type: "interrupted", Content: output.Text})
+	s.send(conn, ServerMessage{Type: "complete", TokenUsage: newTokenUsage(output.TokensUsed)})
+}
+
+// interruptedMessage is the history entry for a response cut short.
+func interruptedMessage(text string) core.Message {
+	if text == "" {
+		return core.NewAssistantMessage(InterruptedMarker)
+	}
+	return core.NewAssistantMessage(text + "\n\n" + InterruptedMarker)
+}
+
+// lockConn serialises writes to a connection, which may come from both the
+// read loop and the goroutine processing messages.
+func (s *Server) lockConn(conn *websocket.Conn) func() {
+	mu, _ := s.writeLocks.LoadOrStore(conn, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
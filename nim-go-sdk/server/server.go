@@ -3,14 +3,17 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
 	"github.com/becomeliminal/nim-go-sdk/core"
@@ -64,10 +67,82 @@ type Config struct {
 	// If nil, no audit logging is performed.
 	AuditLogger engine.AuditLogger
 
+	// AuditErrorHandler is called when AuditLogger fails to record an
+	// entry. If nil, failures are logged.
+	AuditErrorHandler func(ctx context.Context, entry *engine.AuditEntry, err error)
+
+	// Hooks observes the engine lifecycle for tracing and metrics.
+	// If nil, no hooks are called.
+	Hooks engine.Hooks
+
+	// ToolMiddleware is applied to every registered tool, for example
+	// tools.Recover() and tools.Logging(nil).
+	ToolMiddleware []core.ToolMiddleware
+
+	// ResultCache caches results of read-only tools, for example
+	// get_vault_rates. Results are cached per user ID, so it requires an
+	// AuthFunc that returns a distinct ID for each user; the default
+	// handler uses one placeholder ID for everyone.
+	// If nil, tool results are not cached.
+	ResultCache *engine.ResultCache
+
+	// ConfirmationPolicies decide per call whether a tool needs
+	// confirmation, for example engine.AutoApproveDeposits(). The first
+	// policy with an opinion decides.
+	ConfirmationPolicies []core.ConfirmationPolicy
+
+	// UserContext fills in per-user settings before each run, such as the
+	// UserLimits and Preferences.Shortcuts that confirmation policies read.
+	// agentCtx carries the user ID and default preferences. If it fails,
+	// the run continues with the defaults, so policies keep asking.
+	// If nil, users have default preferences and no UserLimits.
+	UserContext func(ctx context.Context, agentCtx *core.Context) error
+
+	// StepUpVerifier checks the stepUpToken sent when confirming actions
+	// that need step-up verification. If nil, such actions cannot be
+	// confirmed.
+	StepUpVerifier StepUpVerifier
+
+	// Compaction summarises older turns with a small model once a
+	// conversation's history grows past its token budget. The summary is
+	// persisted, so resumed conversations stay compact.
+	// If nil, history is replayed in full on every message.
+	Compaction *engine.CompactionConfig
+
+	// ToolConcurrency is the maximum number of read-only tools executed
+	// concurrently within a single turn.
+	// If zero, engine.DefaultToolConcurrency is used.
+	ToolConcurrency int
+
+	// MaxToolCalls caps the total tool calls per request, including calls
+	// made before a confirmation pause.
+	// If zero, core.DefaultLimits().MaxToolCalls is used.
+	MaxToolCalls int
+
+	// ToolQuotas caps calls per tool name per request, for example
+	// {"search_users": 3}. Tools without an entry are only bound by
+	// MaxToolCalls.
+	ToolQuotas map[string]int
+
 	// AnthropicOptions are additional options for the Anthropic client.
 	// This can be used to customize the HTTP client for testing.
 	AnthropicOptions []option.RequestOption
 
+	// AttachmentLimits restricts the images and documents clients may send
+	// with a message. If nil, core.DefaultAttachmentLimits() is used.
+	AttachmentLimits *core.AttachmentLimits
+
+	// ThinkingBudget enables Claude's extended thinking with the given
+	// token budget, at least 1024, on top of MaxTokens. Clients that start
+	// or resume a conversation with "streamThinking" receive the thinking
+	// as thinking_chunk messages. If zero, thinking is disabled.
+	ThinkingBudget int64
+
+	// PromptCaching enables Claude prompt caching for the system prompt,
+	// tool definitions and conversation history. Cache token counts are
+	// reported in the "complete" message.
+	PromptCaching bool
+
 	// DisableStreaming disables streaming mode for the Anthropic API.
 	// When true, uses the non-streaming Messages.New() API instead of NewStreaming().
 	// Useful for testing with mock servers that don't support SSE.
@@ -81,9 +156,15 @@ type Server struct {
 	registry *engine.ToolRegistry
 	upgrader websocket.Upgrader
 
-	conversations store.Conversations
-	confirmations store.Confirmations
-	sessions      sync.Map // *websocket.Conn -> *session
+	conversations    store.Conversations
+	confirmations    store.Confirmations
+	contextManager   *engine.ContextManager // nil unless Compaction is configured
+	attachmentLimits *core.AttachmentLimits
+	sessions         sync.Map // *websocket.Conn -> *session
+	writeLocks       sync.Map // *websocket.Conn -> *sync.Mutex
+	executed         *executedActions
+	owners           *actionOwners
+	lastCleanup      atomic.Int64 // Unix time of the last confirmation cleanup
 }
 
 type session struct {
@@ -92,6 +173,16 @@ type session struct {
 	ConversationID string
 	History        []core.Message
 	TurnCount      int
+
+	// streamThinking sends extended thinking to the client.
+	streamThinking bool
+
+	// pending is set while the last assistant turn awaits confirmation.
+	pending *pendingTurn
+
+	// abandoned holds actions superseded by a new message. They are
+	// cancelled once the new run has finished, unless it reused them.
+	abandoned []*core.PendingAction
 }
 
 // New creates a new server with the given configuration.
@@ -100,6 +191,9 @@ func New(cfg Config) (*Server, error) {
 	if cfg.AnthropicKey == "" {
 		return nil, fmt.Errorf("AnthropicKey is required")
 	}
+	if cfg.ResultCache != nil && cfg.AuthFunc == nil {
+		return nil, fmt.Errorf("ResultCache requires an AuthFunc returning per-user IDs, otherwise cached results are shared between users")
+	}
 
 	// Build Anthropic client options
 	opts := make([]option.RequestOption, 0, len(cfg.AnthropicOptions)+2)
@@ -116,6 +210,7 @@ func New(cfg Config) (*Server, error) {
 
 	// Create registry
 	registry := engine.NewToolRegistry()
+	registry.Use(cfg.ToolMiddleware...)
 
 	// Build engine options
 	var engineOpts []engine.Option
@@ -125,6 +220,21 @@ func New(cfg Config) (*Server, error) {
 	if cfg.AuditLogger != nil {
 		engineOpts = append(engineOpts, engine.WithAudit(cfg.AuditLogger))
 	}
+	if cfg.AuditErrorHandler != nil {
+		engineOpts = append(engineOpts, engine.WithAuditErrorHandler(cfg.AuditErrorHandler))
+	}
+	if cfg.Hooks != nil {
+		engineOpts = append(engineOpts, engine.WithHooks(cfg.Hooks))
+	}
+	if cfg.ResultCache != nil {
+		engineOpts = append(engineOpts, engine.WithResultCache(cfg.ResultCache))
+	}
+	for _, policy := range cfg.ConfirmationPolicies {
+		engineOpts = append(engineOpts, engine.WithConfirmationPolicy(policy))
+	}
+	if cfg.ToolConcurrency > 0 {
+		engineOpts = append(engineOpts, engine.WithToolConcurrency(cfg.ToolConcurrency))
+	}
 
 	// Create engine
 	eng := engine.NewEngine(&client, registry, engineOpts...)
@@ -140,12 +250,26 @@ func New(cfg Config) (*Server, error) {
 		confirmations = store.NewMemoryConfirmations()
 	}
 
+	var contextManager *engine.ContextManager
+	if cfg.Compaction != nil {
+		contextManager = engine.NewContextManager(&client, cfg.Compaction)
+	}
+
+	attachmentLimits := cfg.AttachmentLimits
+	if attachmentLimits == nil {
+		attachmentLimits = core.DefaultAttachmentLimits()
+	}
+
 	return &Server{
-		config:        cfg,
-		engine:        eng,
-		registry:      registry,
-		conversations: conversations,
-		confirmations: confirmations,
+		config:           cfg,
+		engine:           eng,
+		registry:         registry,
+		conversations:    conversations,
+		confirmations:    confirmations,
+		contextManager:   contextManager,
+		attachmentLimits: attachmentLimits,
+		executed:         newExecutedActions(),
+		owners:           newActionOwners(),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins in development
@@ -209,6 +333,10 @@ func (s *Server) defaultLiminalAuthFunc() func(r *http.Request) (string, error)
 	}
 }
 
+// messageOverhead is how far a client message may exceed its encoded
+// attachments, for the message text and JSON framing.
+const messageOverhead = 1 << 20
+
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Authenticate
 	userID := "default-user"
@@ -236,9 +364,29 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
+	// Reject messages larger than the attachment limits allow before they
+	// are read into memory
+	conn.SetReadLimit(s.attachmentLimits.MaxEncodedBytes() + messageOverhead)
+
 	log.Printf("WebSocket connected for user %s", userID)
 
-	var currentSession *session
+	// Messages are processed by a separate goroutine so the read loop can
+	// still receive "stop" while the agent is running
+	ctx, cancel := context.WithCancelCause(r.Context())
+	runs := &runControl{}
+	work := make(chan ClientMessage, maxQueuedMessages)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.processMessages(ctx, conn, userID, runs, work)
+	}()
+	defer func() {
+		close(work)
+		runs.stop(errDisconnected)
+		cancel(errDisconnected)
+		<-done
+		s.writeLocks.Delete(conn)
+	}()
 
 	for {
 		_, msgBytes, err := conn.ReadMessage()
@@ -257,41 +405,82 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 		log.Printf("Received message type=%s from user=%s", msg.Type, userID)
 
-		switch msg.Type {
-		case "new_conversation":
-			currentSession = s.handleNewConversation(r.Context(), conn, userID)
+		if msg.Type == "stop" {
+			if !runs.stop(engine.ErrStopped) {
+				s.sendError(conn, "Nothing to stop")
+			}
+			continue
+		}
 
-		case "resume_conversation":
-			currentSession = s.handleResumeConversation(r.Context(), conn, userID, msg.ConversationID)
+		select {
+		case work <- msg:
+		default:
+			s.sendError(conn, "Too many messages in progress. Please wait for the current response.")
+		}
+	}
+}
 
-		case "message":
-			if currentSession == nil {
-				s.sendError(conn, "No active conversation. Send 'new_conversation' first.")
-				continue
-			}
-			s.handleMessage(r.Context(), conn, currentSession, msg.Content)
+// processMessages handles client messages one at a time, in order. Each
+// message runs under its own context, which "stop" cancels.
+func (s *Server) processMessages(ctx context.Context, conn *websocket.Conn, userID string, runs *runControl, work <-chan ClientMessage) {
+	var currentSession *session
+	for msg := range work {
+		if ctx.Err() != nil {
+			continue // Client has gone; drop queued messages
+		}
+		s.cleanupExpired(ctx)
+		msgCtx := runs.start(ctx)
+		currentSession = s.handleClientMessage(msgCtx, conn, userID, currentSession, msg)
+		runs.finish()
+	}
+}
 
-		case "confirm":
-			if currentSession == nil {
-				s.sendError(conn, "No active conversation")
-				continue
-			}
-			s.handleConfirm(r.Context(), conn, currentSession, userID, msg.ActionID)
+// handleClientMessage dispatches a client message and returns the session
+// that is current afterwards.
+func (s *Server) handleClientMessage(ctx context.Context, conn *websocket.Conn, userID string, currentSession *session, msg ClientMessage) *session {
+	switch msg.Type {
+	case "new_conversation":
+		currentSession = s.handleNewConversation(ctx, conn, userID, msg.StreamThinking)
 
-		case "cancel":
-			if currentSession == nil {
-				s.sendError(conn, "No active conversation")
-				continue
-			}
-			s.handleCancel(r.Context(), conn, currentSession, userID, msg.ActionID)
+	case "resume_conversation":
+		currentSession = s.handleResumeConversation(ctx, conn, userID, msg.ConversationID, msg.StreamThinking)
 
-		default:
-			s.sendError(conn, fmt.Sprintf("Unknown message type: %s", msg.Type))
+	case "message":
+		if currentSession == nil {
+			s.sendError(conn, "No active conversation. Send 'new_conversation' first.")
+			return currentSession
+		}
+		s.handleMessage(ctx, conn, currentSession, msg.Content, msg.Attachments)
+
+	case "confirm":
+		if currentSession == nil {
+			s.sendError(conn, "No active conversation")
+			return currentSession
+		}
+		if msg.PlanID != "" {
+			s.handleConfirmPlan(ctx, conn, currentSession, userID, msg.PlanID, msg.StepUpToken)
+		} else {
+			s.handleConfirm(ctx, conn, currentSession, userID, msg.ActionID, msg.StepUpToken)
+		}
+
+	case "cancel":
+		if currentSession == nil {
+			s.sendError(conn, "No active conversation")
+			return currentSession
 		}
+		if msg.PlanID != "" {
+			s.handleCancelPlan(ctx, conn, currentSession, userID, msg.PlanID)
+		} else {
+			s.handleCancel(ctx, conn, currentSession, userID, msg.ActionID)
+		}
+
+	default:
+		s.sendError(conn, fmt.Sprintf("Unknown message type: %s", msg.Type))
 	}
+	return currentSession
 }
 
-func (s *Server) handleNewConversation(ctx context.Context, conn *websocket.Conn, userID string) *session {
+func (s *Server) handleNewConversation(ctx context.Context, conn *websocket.Conn, userID string, streamThinking bool) *session {
 	conv, err := s.conversations.Create(ctx, userID)
 	if err != nil {
 		s.sendError(conn, fmt.Sprintf("Failed to create conversation: %v", err))
@@ -303,6 +492,7 @@ func (s *Server) handleNewConversation(ctx context.Context, conn *websocket.Conn
 		UserID:         userID,
 		ConversationID: conv.ID,
 		History:        []core.Message{},
+		streamThinking: streamThinking,
 	}
 	s.sessions.Store(conn, sess)
 
@@ -315,27 +505,23 @@ func (s *Server) handleNewConversation(ctx context.Context, conn *websocket.Conn
 	return sess
 }
 
-func (s *Server) handleResumeConversation(ctx context.Context, conn *websocket.Conn, userID, conversationID string) *session {
+func (s *Server) handleResumeConversation(ctx context.Context, conn *websocket.Conn, userID, conversationID string, streamThinking bool) *session {
 	conv, err := s.conversations.Get(ctx, conversationID)
 	if err != nil {
 		s.sendError(conn, "Conversation not found")
 		return nil
 	}
 
-	// Convert stored messages to core.Message
-	history := make([]core.Message, 0, len(conv.Messages))
-	for _, m := range conv.Messages {
-		history = append(history, core.Message{
-			Role:    core.Role(m.Role),
-			Content: m.Content,
-		})
-	}
+	// Convert stored messages to core.Message, starting from the latest
+	// summary if the conversation was compacted
+	history := restoreHistory(conv.Messages)
 
 	sess := &session{
 		ID:             conversationID,
 		UserID:         userID,
 		ConversationID: conversationID,
 		History:        history,
+		streamThinking: streamThinking,
 	}
 	s.sessions.Store(conn, sess)
 
@@ -349,32 +535,93 @@ func (s *Server) handleResumeConversation(ctx context.Context, conn *websocket.C
 	return sess
 }
 
-func (s *Server) handleMessage(ctx context.Context, conn *websocket.Conn, sess *session, content string) {
-	if content == "" {
+func (s *Server) handleMessage(ctx context.Context, conn *websocket.Conn, sess *session, content string, attachments []Attachment) {
+	if content == "" && len(attachments) == 0 {
+		return
+	}
+
+	files := make([]core.AttachmentContent, len(attachments))
+	for i, a := range attachments {
+		files[i] = a.toCore()
+	}
+	if err := s.attachmentLimits.Validate(files); err != nil {
+		s.sendError(conn, fmt.Sprintf("Invalid attachment: %v", err))
 		return
 	}
 
 	log.Printf("[CONVERSATION %s] USER: %s", sess.ConversationID, truncate(content, 50))
 
+	// A new message supersedes any actions still awaiting confirmation
+	if sess.pending != nil {
+		s.abandonPending(ctx, sess)
+	}
+
 	// Add to history
-	sess.History = append(sess.History, core.NewUserMessage(content))
+	sess.History = append(sess.History, core.NewUserMessageWithAttachments(content, files))
 	sess.TurnCount++
 
-	// Persist user message
-	s.persistMessage(ctx, sess.ConversationID, "user", content)
+	// Persist user message, with its attachments so they survive a resume
+	err := s.conversations.Append(ctx, &store.AppendMessage{
+		ConversationID: sess.ConversationID,
+		Role:           "user",
+		Content:        content,
+		Attachments:    files,
+	})
+	if err != nil {
+		log.Printf("Failed to persist message: %v", err)
+	}
+
+	// Keep long conversations within the context budget
+	s.compactHistory(ctx, sess)
 
 	// Build input
-	agentCtx := core.NewContext(sess.UserID, sess.ID, sess.ConversationID, sess.ID)
+	input := s.newInput(ctx, sess)
+	input.UserMessage = content
+	input.Attachments = files
+
+	s.runAgent(ctx, conn, sess, input)
+	s.cancelAbandoned(ctx, sess)
+}
+
+// newInput builds the engine input for a session. The last history entry is
+// the message being sent, so it is excluded from History.
+func (s *Server) newInput(ctx context.Context, sess *session) *engine.Input {
+	requestID := uuid.New().String()
+	agentCtx := s.newContext(sess, requestID)
+	if s.config.UserContext != nil {
+		if err := s.config.UserContext(ctx, agentCtx); err != nil {
+			log.Printf("Failed to load user context for %s: %v", sess.UserID, err)
+			agentCtx = s.newContext(sess, requestID)
+		}
+	}
+
+	return &engine.Input{
+		Context:        agentCtx,
+		History:        sess.History[:len(sess.History)-1],
+		SystemPrompt:   s.config.SystemPrompt,
+		Model:          s.config.Model,
+		MaxTokens:      s.config.MaxTokens,
+		PromptCaching:  s.config.PromptCaching,
+		ThinkingBudget: s.config.ThinkingBudget,
+	}
+}
 
-	input := &engine.Input{
-		UserMessage:  content,
-		Context:      agentCtx,
-		History:      sess.History[:len(sess.History)-1],
-		SystemPrompt: s.config.SystemPrompt,
-		Model:        s.config.Model,
-		MaxTokens:    s.config.MaxTokens,
+// newContext builds the default execution context for a request, with the
+// configured tool call limits applied.
+func (s *Server) newContext(sess *session, requestID string) *core.Context {
+	agentCtx := core.NewContext(sess.UserID, sess.ID, sess.ConversationID, requestID)
+	if s.config.MaxToolCalls > 0 {
+		agentCtx.Limits.MaxToolCalls = s.config.MaxToolCalls
 	}
+	if s.config.ToolQuotas != nil {
+		agentCtx.Limits.ToolQuotas = s.config.ToolQuotas
+	}
+	return agentCtx
+}
 
+// runAgent runs the engine with streaming enabled (unless disabled) and
+// progress events, and delivers the output to the client.
+func (s *Server) runAgent(ctx context.Context, conn *websocket.Conn, sess *session, input *engine.Input) {
 	// Only enable streaming if not disabled (streaming requires SSE-compatible server)
 	if !s.config.DisableStreaming {
 		input.StreamCallback = func(chunk string, done bool) {
@@ -384,6 +631,16 @@ func (s *Server) handleMessage(ctx context.Context, conn *websocket.Conn, sess *
 		}
 	}
 
+	// Report tool activity so the client can show progress
+	input.EventCallback = func(event engine.Event) {
+		if event.Type == engine.EventThinkingDelta && !sess.streamThinking {
+			return
+		}
+		if msg, ok := eventMessage(event); ok {
+			s.send(conn, msg)
+		}
+	}
+
 	// Run agent
 	output, err := s.engine.Run(ctx, input)
 	if err != nil {
@@ -405,46 +662,105 @@ func (s *Server) handleOutput(ctx context.Context, conn *websocket.Conn, sess *s
 		s.persistMessage(ctx, sess.ConversationID, "assistant", output.Text)
 
 		s.send(conn, ServerMessage{Type: "text", Content: output.Text})
-		s.send(conn, ServerMessage{
-			Type: "complete",
-			TokenUsage: &TokenUsage{
-				InputTokens:  output.TokensUsed.InputTokens,
-				OutputTokens: output.TokensUsed.OutputTokens,
-				TotalTokens:  output.TokensUsed.TotalTokens(),
-			},
-		})
+		s.send(conn, ServerMessage{Type: "complete", TokenUsage: newTokenUsage(output.TokensUsed)})
 
 	case engine.OutputConfirmationNeeded:
 		pending := output.PendingAction
 
-		// Store confirmation
-		if err := s.confirmations.Store(ctx, pending); err != nil {
-			log.Printf("Failed to store confirmation: %v", err)
+		// Store confirmations, reusing any identical action still pending
+		// so the same write can only be confirmed and executed once
+		actions := make([]Confirmation, 0, len(output.PendingActions))
+		for _, action := range output.PendingActions {
+			existing, err := s.confirmations.GetByIdempotency(ctx, action.UserID, action.IdempotencyKey)
+			if err != nil {
+				log.Printf("Failed to look up idempotency key: %v", err)
+			} else if existing != nil {
+				log.Printf("Reusing pending action %s for duplicate %s request", existing.ID, action.Tool)
+				action.ID = existing.ID
+			}
+			if err := s.confirmations.Store(ctx, action); err != nil {
+				log.Printf("Failed to store confirmation: %v", err)
+			}
+			s.owners.claim(action, sess)
+			actions = append(actions, Confirmation{
+				ID:        action.ID,
+				Tool:      action.Tool,
+				Summary:   action.Summary,
+				Details:   newActionDetails(action.Details),
+				StepUp:    action.ConfirmationLevel == core.ConfirmationStepUp,
+				Reason:    action.ConfirmationReason,
+				ExpiresAt: action.ExpiresAt,
+				PlanStep:  action.PlanStep,
+			})
 		}
 
 		sess.History = append(sess.History, core.NewAssistantMessageWithBlocks(output.ResponseBlocks))
+		sess.pending = newPendingTurn(output)
 
 		s.send(conn, ServerMessage{
 			Type:      "confirm_request",
 			ActionID:  pending.ID,
 			Tool:      pending.Tool,
 			Summary:   pending.Summary,
+			Details:   newActionDetails(pending.Details),
 			Content:   output.Text,
 			ExpiresAt: time.Unix(pending.ExpiresAt, 0).Format(time.RFC3339),
+			Actions:   actions,
+			PlanID:    pending.PlanID,
 		})
 
+	case engine.OutputInterrupted:
+		s.handleInterrupted(ctx, conn, sess, output)
+
 	case engine.OutputError:
 		log.Printf("Agent error: %v", output.Error)
+
+		var blocked *engine.GuardrailError
+		if errors.As(output.Error, &blocked) {
+			s.sendBlocked(conn, blocked.Result)
+			return
+		}
 		s.sendError(conn, output.Error.Error())
 	}
 }
 
-func (s *Server) handleConfirm(ctx context.Context, conn *websocket.Conn, sess *session, userID, actionID string) {
+func (s *Server) handleConfirm(ctx context.Context, conn *websocket.Conn, sess *session, userID, actionID, stepUpToken string) {
 	log.Printf("Processing confirmation for action=%s, user=%s", actionID, userID)
 
+	// Actions needing step-up stay pending until verification succeeds
+	if pending, err := s.confirmations.Get(ctx, userID, actionID); err == nil {
+		if !s.verifyStepUp(ctx, conn, userID, []*core.PendingAction{pending}, stepUpToken) {
+			return
+		}
+	}
+
 	// Get and remove confirmation
 	action, err := s.confirmations.Confirm(ctx, userID, actionID)
 	if err != nil {
+		if done, ok := s.executed.get(actionID); ok {
+			// Confirmed elsewhere, for example on another connection that
+			// reused the action: report its real outcome
+			switch {
+			case !done.finished:
+				s.sendError(conn, "That action is already being completed.")
+			case sess.pending != nil && sess.pending.has(actionID):
+				s.resolveAction(ctx, conn, sess, done.action, done.result)
+			default:
+				s.sendError(conn, "That action has already been completed.")
+			}
+			return
+		}
+		if sess.pending != nil && sess.pending.has(actionID) {
+			expired := sess.pending.action(actionID)
+			if !s.expiryAudited(expired) {
+				s.engine.AuditConfirmation(ctx, engine.AuditEventConfirmationExpired, expired, "")
+			}
+			s.resolveAction(ctx, conn, sess, expired, core.ToolResultContent{
+				Content: "Confirmation expired before the user approved it",
+				IsError: true,
+			})
+			return
+		}
 		s.send(conn, ServerMessage{
 			Type:    "text",
 			Content: "That action expired. Would you like me to set it up again?",
@@ -453,44 +769,31 @@ func (s *Server) handleConfirm(ctx context.Context, conn *websocket.Conn, sess *
 		return
 	}
 
-	// Execute the confirmed tool
-	result, err := s.engine.ExecuteTool(ctx, userID, action.Tool, action.Input, action.ID)
+	// Execute the confirmed tool and hand the result back to Claude so it
+	// can continue from where it paused
+	s.engine.AuditConfirmation(ctx, engine.AuditEventConfirmationConfirmed, action, "")
+	s.executed.add(action)
+	// The write finishes even if the user stops the run meanwhile
+	result, err := s.engine.ExecuteAction(context.WithoutCancel(ctx), action)
+	content := actionResult(action, result, err)
+	s.executed.finish(action.ID, content)
+	s.resolveAction(ctx, conn, sess, action, content)
+}
 
-	var resultContent string
-	var isError bool
+// actionResult converts the outcome of a confirmed action into a tool result.
+func actionResult(action *core.PendingAction, result *core.ToolResult, err error) core.ToolResultContent {
+	content := core.ToolResultContent{ToolUseID: action.BlockID}
 	if err != nil {
-		resultContent = fmt.Sprintf("Error: %v", err)
-		isError = true
+		content.Content = fmt.Sprintf("Error: %v", err)
+		content.IsError = true
 	} else if !result.Success {
-		resultContent = result.Error
-		isError = true
+		content.Content = result.Error
+		content.IsError = true
 	} else {
 		resultBytes, _ := json.Marshal(result.Data)
-		resultContent = string(resultBytes)
-	}
-
-	// Add tool result to history
-	sess.History = append(sess.History, core.NewToolResultMessage([]core.ToolResultContent{
-		{ToolUseID: action.BlockID, Content: resultContent, IsError: isError},
-	}))
-
-	if isError {
-		s.send(conn, ServerMessage{
-			Type:    "text",
-			Content: fmt.Sprintf("Sorry, that action failed: %s", resultContent),
-		})
-		s.send(conn, ServerMessage{Type: "complete"})
-		return
+		content.Content = string(resultBytes)
 	}
-
-	// Format success message
-	resultMsg := formatToolResult(action.Tool, result.Data)
-	sess.History = append(sess.History, core.NewAssistantMessage(resultMsg))
-
-	s.persistMessage(ctx, sess.ConversationID, "assistant", resultMsg)
-
-	s.send(conn, ServerMessage{Type: "text", Content: resultMsg})
-	s.send(conn, ServerMessage{Type: "complete"})
+	return content
 }
 
 func (s *Server) handleCancel(ctx context.Context, conn *websocket.Conn, sess *session, userID, actionID string) {
@@ -502,20 +805,152 @@ func (s *Server) handleCancel(ctx context.Context, conn *websocket.Conn, sess *s
 	}
 
 	// Cancel the action
-	if err := s.confirmations.Cancel(ctx, userID, actionID); err != nil {
+	cancelled, err := s.cancelAction(ctx, sess, actionID)
+	if err != nil {
 		s.sendError(conn, "Failed to cancel action")
 		return
 	}
+	if cancelled {
+		s.engine.AuditConfirmation(ctx, engine.AuditEventConfirmationCancelled, action, "Cancelled by user")
+	}
+
+	// Let Claude know the action was cancelled so it can respond accordingly
+	s.resolveAction(ctx, conn, sess, action, core.ToolResultContent{
+		ToolUseID: action.BlockID,
+		Content:   "Cancelled by user",
+		IsError:   true,
+	})
+}
+
+// resolveAction records the result of a confirmed or cancelled action.
+// Once every action in the paused turn is resolved, the agent resumes with
+// the results of all tool_use blocks in that turn.
+func (s *Server) resolveAction(ctx context.Context, conn *websocket.Conn, sess *session, action *core.PendingAction, result core.ToolResultContent) {
+	s.owners.release(action.ID, sess)
+	if sess.pending == nil || !sess.pending.has(action.ID) {
+		s.replyDetached(ctx, conn, sess, action, result)
+		return
+	}
+
+	sess.pending.resolve(action.ID, result)
+	if !sess.pending.done() {
+		s.send(conn, ServerMessage{
+			Type:     "action_result",
+			ActionID: action.ID,
+			Content:  result.Content,
+			IsError:  result.IsError,
+		})
+		return
+	}
 
-	// Add cancelled tool result to history
-	sess.History = append(sess.History, core.NewToolResultMessage([]core.ToolResultContent{
-		{ToolUseID: action.BlockID, Content: "Cancelled by user", IsError: true},
-	}))
+	s.finishPending(ctx, conn, sess)
+}
+
+// replyDetached reports the outcome of an action whose paused turn is not
+// in the session history, for example because it was proposed before the
+// conversation was resumed on a new connection. Claude is not called, as
+// the tool_result would have no matching tool_use.
+func (s *Server) replyDetached(ctx context.Context, conn *websocket.Conn, sess *session, action *core.PendingAction, result core.ToolResultContent) {
+	text := "Done: " + action.Summary
+	if result.IsError {
+		text = fmt.Sprintf("%s was not completed: %s", action.Summary, result.Content)
+	}
 
-	s.send(conn, ServerMessage{Type: "text", Content: "Action cancelled."})
+	sess.History = append(sess.History, core.NewAssistantMessage(text))
+	s.persistMessage(ctx, sess.ConversationID, "assistant", text)
+
+	s.send(conn, ServerMessage{Type: "text", Content: text})
 	s.send(conn, ServerMessage{Type: "complete"})
 }
 
+// finishPending resumes the agent with the results of a fully resolved turn.
+func (s *Server) finishPending(ctx context.Context, conn *websocket.Conn, sess *session) {
+	pending := sess.pending
+	sess.pending = nil
+	s.resumeAgent(ctx, conn, sess, pending.requestID, pending.toolCalls, pending.orderedResults()...)
+}
+
+// abandonPending closes the paused turn in history, so the next request
+// stays well-formed. Its outstanding actions stay stored until the next run
+// has finished, so an identical write proposed again reuses them;
+// cancelAbandoned then cancels the rest. Actions already confirmed on
+// another connection are closed with their real result.
+func (s *Server) abandonPending(ctx context.Context, sess *session) {
+	for _, actionID := range sess.pending.outstanding() {
+		if done, ok := s.executed.get(actionID); ok {
+			result := done.result
+			if !done.finished {
+				result = core.ToolResultContent{Content: "Confirmed by the user on another device; the result is not known yet"}
+			}
+			sess.pending.resolve(actionID, result)
+			continue
+		}
+		sess.abandoned = append(sess.abandoned, sess.pending.action(actionID))
+		sess.pending.resolve(actionID, core.ToolResultContent{
+			Content: abandonedReason,
+			IsError: true,
+		})
+	}
+
+	sess.History = append(sess.History, core.NewToolResultMessage(sess.pending.orderedResults()))
+	sess.pending = nil
+}
+
+// abandonedReason is the result of an action superseded by a new message.
+const abandonedReason = "Cancelled: the user sent a new message instead of confirming"
+
+// cancelAbandoned cancels the abandoned actions that the run after them did
+// not propose again.
+func (s *Server) cancelAbandoned(ctx context.Context, sess *session) {
+	ctx = context.WithoutCancel(ctx)
+	for _, action := range sess.abandoned {
+		if sess.pending != nil && sess.pending.has(action.ID) {
+			continue // Reused by the new turn
+		}
+		cancelled, err := s.cancelAction(ctx, sess, action.ID)
+		if err != nil {
+			if _, ok := s.executed.get(action.ID); !ok {
+				log.Printf("Failed to cancel abandoned action %s: %v", action.ID, err)
+			}
+			continue
+		}
+		if cancelled {
+			s.engine.AuditConfirmation(ctx, engine.AuditEventConfirmationCancelled, action, abandonedReason)
+		}
+	}
+	sess.abandoned = nil
+}
+
+// cancelAction cancels a stored action on behalf of sess and reports whether
+// it was cancelled. An action that a duplicate request on another
+// connection reused is left stored for that connection.
+func (s *Server) cancelAction(ctx context.Context, sess *session, actionID string) (bool, error) {
+	if !s.owners.release(actionID, sess) {
+		return false, nil
+	}
+	if err := s.confirmations.Cancel(ctx, sess.UserID, actionID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// resumeAgent adds tool results for a paused run to the history and
+// continues the agent loop with them. The resumed run keeps the paused
+// request's ID so both halves are audited as one request, and the tool
+// calls it already made so its quotas carry over.
+func (s *Server) resumeAgent(ctx context.Context, conn *websocket.Conn, sess *session, requestID string, toolCalls map[string]int, results ...core.ToolResultContent) {
+	sess.History = append(sess.History, core.NewToolResultMessage(results))
+
+	input := s.newInput(ctx, sess)
+	input.ToolResults = results
+	input.PriorToolCalls = toolCalls
+	if requestID != "" {
+		input.Context.RequestID = requestID
+	}
+
+	s.runAgent(ctx, conn, sess, input)
+}
+
 func (s *Server) persistMessage(ctx context.Context, conversationID string, role, content string) {
 	err := s.conversations.Append(ctx, &store.AppendMessage{
 		ConversationID: conversationID,
@@ -528,6 +963,8 @@ func (s *Server) persistMessage(ctx context.Context, conversationID string, role
 }
 
 func (s *Server) send(conn *websocket.Conn, msg ServerMessage) {
+	unlock := s.lockConn(conn)
+	defer unlock()
 	if err := conn.WriteJSON(msg); err != nil {
 		log.Printf("Failed to send message: %v", err)
 	}
@@ -538,22 +975,26 @@ func (s *Server) sendError(conn *websocket.Conn, content string) {
 	s.send(conn, ServerMessage{Type: "error", Content: content})
 }
 
+// sendBlocked sends a structured error for a request blocked by guardrails.
+func (s *Server) sendBlocked(conn *websocket.Conn, result *engine.GuardrailResult) {
+	code := "rate_limited"
+	content := "You're sending requests too quickly. Please wait a moment and try again."
+	if result.CircuitState == engine.CircuitOpen || result.CircuitState == engine.CircuitHalfOpen {
+		code = "circuit_open"
+		content = "The assistant is temporarily unavailable. Please try again shortly."
+	}
+
+	msg := ServerMessage{Type: "error", Content: content, Code: code}
+	if result.RetryAfter > 0 {
+		msg.RetryAfter = time.Unix(result.RetryAfter, 0).Format(time.RFC3339)
+	}
+	log.Printf("Request blocked by guardrails: %s", result.Warning)
+	s.send(conn, msg)
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
 	}
 	return s[:maxLen-3] + "..."
 }
-
-func formatToolResult(tool string, result interface{}) string {
-	switch r := result.(type) {
-	case map[string]interface{}:
-		if msg, ok := r["message"].(string); ok {
-			return msg
-		}
-		if success, ok := r["success"].(bool); ok && success {
-			return "Done! " + tool + " completed successfully."
-		}
-	}
-	return "Action completed."
-}
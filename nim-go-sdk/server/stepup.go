@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// StepUpVerifier checks the additional verification a client supplies when
+// confirming an action whose confirmation policy asked for step-up, such
+// as a PIN or a biometric assertion issued by the client's auth provider.
+// A nil error approves the confirmation.
+type StepUpVerifier func(ctx context.Context, userID string, action *core.PendingAction, token string) error
+
+// verifyStepUp checks the step-up token for every action that needs it and
+// reports whether confirmation may proceed. On failure the client is told
+// why and the actions stay pending, so the user can try again.
+func (s *Server) verifyStepUp(ctx context.Context, conn *websocket.Conn, userID string, actions []*core.PendingAction, token string) bool {
+	for _, action := range actions {
+		if action.ConfirmationLevel != core.ConfirmationStepUp {
+			continue
+		}
+		if s.config.StepUpVerifier == nil {
+			log.Printf("Step-up required for action %s but no verifier is configured", action.ID)
+			s.send(conn, ServerMessage{Type: "error", Content: "This action needs additional verification, which is not available.", Code: "step_up_unavailable", ActionID: action.ID})
+			return false
+		}
+		if token == "" {
+			s.send(conn, ServerMessage{Type: "error", Content: "Please verify your identity to confirm this action.", Code: "step_up_required", ActionID: action.ID})
+			return false
+		}
+		if err := s.config.StepUpVerifier(ctx, userID, action, token); err != nil {
+			log.Printf("Step-up verification failed for action %s: %v", action.ID, err)
+			s.send(conn, ServerMessage{Type: "error", Content: "Verification failed. Please try again.", Code: "step_up_failed", ActionID: action.ID})
+			return false
+		}
+	}
+	return true
+}
@@ -113,18 +113,23 @@ func (m *MemoryConfirmations) Cancel(ctx context.Context, userID, actionID strin
 }
 
 func (m *MemoryConfirmations) Cleanup(ctx context.Context) (int, error) {
+	expired, err := m.CleanupExpired(ctx)
+	return len(expired), err
+}
+
+func (m *MemoryConfirmations) CleanupExpired(ctx context.Context) ([]*core.PendingAction, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	now := time.Now().Unix()
-	count := 0
+	var expired []*core.PendingAction
 	for _, action := range m.actions {
 		if action.ExpiresAt < now {
 			m.deleteUnlocked(action)
-			count++
+			expired = append(expired, action)
 		}
 	}
-	return count, nil
+	return expired, nil
 }
 
 func (m *MemoryConfirmations) deleteUnlocked(action *core.PendingAction) {
@@ -135,4 +140,7 @@ func (m *MemoryConfirmations) deleteUnlocked(action *core.PendingAction) {
 }
 
 // Verify MemoryConfirmations implements Confirmations.
-var _ Confirmations = (*MemoryConfirmations)(nil)
+var (
+	_ Confirmations         = (*MemoryConfirmations)(nil)
+	_ ExpiringConfirmations = (*MemoryConfirmations)(nil)
+)
@@ -69,12 +69,15 @@ func (m *MemoryConversations) Append(ctx context.Context, msg *AppendMessage) er
 	}
 
 	stored := StoredMessage{
-		ID:        uuid.New().String(),
-		Role:      msg.Role,
-		Content:   msg.Content,
-		Blocks:    msg.Blocks,
-		Tools:     msg.Tools,
-		CreatedAt: time.Now(),
+		ID:          uuid.New().String(),
+		Role:        msg.Role,
+		Content:     msg.Content,
+		Blocks:      msg.Blocks,
+		Tools:       msg.Tools,
+		Kind:        msg.Kind,
+		KeptTurns:   msg.KeptTurns,
+		CreatedAt:   time.Now(),
+		Attachments: msg.Attachments,
 	}
 
 	conv.Messages = append(conv.Messages, stored)
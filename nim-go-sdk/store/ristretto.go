@@ -158,12 +158,18 @@ func (r *RistrettoConfirmations) Cancel(ctx context.Context, userID, actionID st
 }
 
 func (r *RistrettoConfirmations) Cleanup(ctx context.Context) (int, error) {
-	// Ristretto handles TTL-based eviction automatically.
-	// This method cleans up expired entries from our tracking map.
+	expired, err := r.CleanupExpired(ctx)
+	return len(expired), err
+}
+
+func (r *RistrettoConfirmations) CleanupExpired(ctx context.Context) ([]*core.PendingAction, error) {
+	// Ristretto handles TTL-based eviction automatically, but entries are
+	// kept for expiryRetention past their expiry so cleanup can return them.
+	// This method also cleans up expired entries from our tracking map.
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	count := 0
+	var expired []*core.PendingAction
 	now := time.Now().Unix()
 
 	for userID, actions := range r.actionsByUser {
@@ -172,7 +178,6 @@ func (r *RistrettoConfirmations) Cleanup(ctx context.Context) (int, error) {
 			val, found := r.cache.Get(key)
 			if !found {
 				delete(actions, actionID)
-				count++
 				continue
 			}
 
@@ -183,7 +188,7 @@ func (r *RistrettoConfirmations) Cleanup(ctx context.Context) (int, error) {
 					r.idempotency.Del(r.idempotencyKey(userID, action.IdempotencyKey))
 				}
 				delete(actions, actionID)
-				count++
+				expired = append(expired, action)
 			}
 		}
 
@@ -192,7 +197,7 @@ func (r *RistrettoConfirmations) Cleanup(ctx context.Context) (int, error) {
 		}
 	}
 
-	return count, nil
+	return expired, nil
 }
 
 // Close releases resources used by the cache.
@@ -227,15 +232,22 @@ func (r *RistrettoConfirmations) idempotencyKey(userID, key string) string {
 	return userID + ":idemp:" + key
 }
 
+// expiryRetention is how long an action stays cached after it expires, so
+// CleanupExpired can still return it. Get rejects it as soon as it expires.
+const expiryRetention = 5 * time.Minute
+
 func (r *RistrettoConfirmations) ttlFor(action *core.PendingAction) time.Duration {
 	if action.ExpiresAt > 0 {
 		ttl := time.Until(time.Unix(action.ExpiresAt, 0))
 		if ttl > 0 {
-			return ttl
+			return ttl + expiryRetention
 		}
 	}
-	return r.defaultTTL
+	return r.defaultTTL + expiryRetention
 }
 
 // Verify RistrettoConfirmations implements Confirmations.
-var _ Confirmations = (*RistrettoConfirmations)(nil)
+var (
+	_ Confirmations         = (*RistrettoConfirmations)(nil)
+	_ ExpiringConfirmations = (*RistrettoConfirmations)(nil)
+)
@@ -33,6 +33,14 @@ type Confirmations interface {
 	Cleanup(ctx context.Context) (int, error)
 }
 
+// ExpiringConfirmations is implemented by Confirmations stores that can
+// return the actions their cleanup removed, so each expiry can be audited.
+// Both SDK stores implement it.
+type ExpiringConfirmations interface {
+	// CleanupExpired removes all expired actions and returns them.
+	CleanupExpired(ctx context.Context) ([]*core.PendingAction, error)
+}
+
 // Conversations stores conversation history.
 // The SDK provides MemoryConversations for development.
 // Production deployments should implement with PostgreSQL or similar.
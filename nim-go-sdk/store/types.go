@@ -1,6 +1,10 @@
 package store
 
-import "time"
+import (
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
 
 // Conversation represents conversation metadata.
 type Conversation struct {
@@ -17,6 +21,14 @@ type ConversationWithMessages struct {
 	Messages []StoredMessage `json:"messages"`
 }
 
+// MessageKindSummary marks a stored message that summarises the earlier
+// conversation after history compaction.
+const MessageKindSummary = "summary"
+
+// MessageKindInterrupted marks an assistant message the user stopped
+// before it was complete.
+const MessageKindInterrupted = "interrupted"
+
 // StoredMessage represents a persisted message.
 type StoredMessage struct {
 	ID        string        `json:"id"`
@@ -24,7 +36,12 @@ type StoredMessage struct {
 	Content   string        `json:"content"`
 	Blocks    []interface{} `json:"blocks,omitempty"`
 	Tools     []interface{} `json:"tools,omitempty"`
+	Kind      string        `json:"kind,omitempty"`       // Empty for ordinary messages, otherwise a MessageKind constant
+	KeptTurns int           `json:"kept_turns,omitempty"` // For summaries: user turns before the summary it does not replace
 	CreatedAt time.Time     `json:"created_at"`
+
+	// Attachments are the images and documents sent with a user message.
+	Attachments []core.AttachmentContent `json:"attachments,omitempty"`
 }
 
 // AppendMessage contains data for adding a message to a conversation.
@@ -34,4 +51,7 @@ type AppendMessage struct {
 	Content        string
 	Blocks         []interface{}
 	Tools          []interface{}
+	Kind           string
+	KeptTurns      int
+	Attachments    []core.AttachmentContent
 }
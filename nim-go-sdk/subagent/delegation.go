@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/engine"
 	"github.com/becomeliminal/nim-go-sdk/tools"
 )
 
@@ -113,19 +117,28 @@ func (d *DelegationTool) Execute(ctx context.Context, params *core.ToolParams) (
 
 	// Create a minimal context for the sub-agent
 	// The sub-agent context should be created from the parent context
-	// but we only have userID here, so we create a basic one
-	subCtx := &core.Context{
+	// but we only have userID and requestID here, so we create a basic one.
+	// Each delegation gets its own request ID, linked to the parent request
+	// so its audit entries form a subtree of the parent's.
+	parentCtx := &core.Context{
 		UserID:    params.UserID,
 		RequestID: params.RequestID,
-		Limits:    core.SubAgentLimits(),
 	}
+	subCtx := parentCtx.ForSubAgent(uuid.New().String())
 
-	// Run sub-agent
+	// Run sub-agent, reporting progress to the parent run's client
+	engine.EmitEvent(ctx, engine.Event{
+		Type:      engine.EventSubAgentStart,
+		ToolName:  d.Name(),
+		AgentName: d.subagent.Name(),
+	})
+	start := time.Now()
 	output, err := d.subagent.Run(ctx, &core.Input{
 		UserMessage: task,
 		Context:     subCtx,
 	})
 	if err != nil {
+		d.emitEnd(ctx, start, false, err.Error())
 		return &core.ToolResult{
 			Success: false,
 			Error:   fmt.Sprintf("sub-agent error: %v", err),
@@ -134,6 +147,7 @@ func (d *DelegationTool) Execute(ctx context.Context, params *core.ToolParams) (
 
 	// Convert output to result
 	result := ToResult(d.subagent.Name(), output)
+	d.emitEnd(ctx, start, result.Success, result.Error)
 
 	if !result.Success {
 		return &core.ToolResult{
@@ -153,6 +167,18 @@ func (d *DelegationTool) Execute(ctx context.Context, params *core.ToolParams) (
 	}, nil
 }
 
+// emitEnd reports that the sub-agent has finished its task.
+func (d *DelegationTool) emitEnd(ctx context.Context, start time.Time, success bool, errMsg string) {
+	engine.EmitEvent(ctx, engine.Event{
+		Type:      engine.EventSubAgentEnd,
+		ToolName:  d.Name(),
+		AgentName: d.subagent.Name(),
+		Duration:  time.Since(start),
+		Success:   success,
+		Error:     errMsg,
+	})
+}
+
 // GetSummary returns a summary of the delegation.
 func (d *DelegationTool) GetSummary(input json.RawMessage) string {
 	return fmt.Sprintf("Delegate to %s specialist", d.subagent.Name())
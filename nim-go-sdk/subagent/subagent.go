@@ -156,6 +156,10 @@ func ToResult(agentName string, output *core.Output) *SubAgentResult {
 		if output.Error != nil {
 			result.Error = output.Error.Error()
 		}
+	case core.OutputInterrupted:
+		result.Success = false
+		result.Response = output.Text
+		result.Error = "sub-agent was interrupted"
 	case core.OutputConfirmationNeeded:
 		// Sub-agents should never reach this state
 		result.Success = false
@@ -15,7 +15,11 @@ type Builder struct {
 	schema               map[string]interface{}
 	requiresConfirmation bool
 	summaryTemplate      string
+	actionType           string
+	preflight            core.PreflightFunc
+	confirmationPolicy   core.ConfirmationPolicy
 	handler              core.ToolHandler
+	middleware           []core.ToolMiddleware
 }
 
 // New creates a new tool builder.
@@ -50,6 +54,27 @@ func (b *Builder) SummaryTemplate(template string) *Builder {
 	return b
 }
 
+// ActionType sets the kind of write the tool performs, such as
+// core.ActionTypeSend, so confirmations carry structured details.
+func (b *Builder) ActionType(actionType string) *Builder {
+	b.actionType = actionType
+	return b
+}
+
+// Preflight sets a function that completes the confirmation details with
+// reads made before the user confirms.
+func (b *Builder) Preflight(fn core.PreflightFunc) *Builder {
+	b.preflight = fn
+	return b
+}
+
+// ConfirmationPolicy sets a policy that decides per call whether the tool
+// needs confirmation, overriding RequiresConfirmation.
+func (b *Builder) ConfirmationPolicy(policy core.ConfirmationPolicy) *Builder {
+	b.confirmationPolicy = policy
+	return b
+}
+
 // Handler sets the execution handler for the tool.
 func (b *Builder) Handler(h core.ToolHandler) *Builder {
 	b.handler = h
@@ -68,15 +93,29 @@ func (b *Builder) HandlerFunc(fn func(ctx context.Context, input json.RawMessage
 	return b
 }
 
+// Use adds middleware around the tool's handler, such as Timeout or Retry.
+// Middleware added first is the outermost.
+func (b *Builder) Use(middleware ...core.ToolMiddleware) *Builder {
+	b.middleware = append(b.middleware, middleware...)
+	return b
+}
+
 // Build creates the tool.
 func (b *Builder) Build() core.Tool {
+	handler := b.handler
+	if handler != nil {
+		handler = core.Chain(handler, b.middleware...)
+	}
 	return core.NewBaseTool(core.ToolDefinition{
 		ToolName:                 b.name,
 		ToolDescription:          b.description,
 		RequiresUserConfirmation: b.requiresConfirmation,
 		SummaryTemplate:          b.summaryTemplate,
 		InputSchema:              b.schema,
-	}, b.handler)
+		ActionType:               b.actionType,
+		Preflight:                b.preflight,
+		ConfirmationPolicy:       b.confirmationPolicy,
+	}, handler)
 }
 
 // Config provides a declarative way to create a tool.
@@ -86,7 +125,11 @@ type Config struct {
 	Schema               map[string]interface{}
 	RequiresConfirmation bool
 	SummaryTemplate      string
+	ActionType           string
+	Preflight            core.PreflightFunc
+	ConfirmationPolicy   core.ConfirmationPolicy
 	Handler              func(ctx context.Context, input json.RawMessage) (interface{}, error)
+	Middleware           []core.ToolMiddleware
 }
 
 // FromConfig creates a tool from a Config struct.
@@ -105,5 +148,8 @@ func FromConfig(cfg Config) core.Tool {
 		RequiresUserConfirmation: cfg.RequiresConfirmation,
 		SummaryTemplate:          cfg.SummaryTemplate,
 		InputSchema:              cfg.Schema,
-	}, handler)
+		ActionType:               cfg.ActionType,
+		Preflight:                cfg.Preflight,
+		ConfirmationPolicy:       cfg.ConfirmationPolicy,
+	}, core.Chain(handler, cfg.Middleware...))
 }
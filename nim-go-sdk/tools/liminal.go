@@ -55,7 +55,8 @@ func LiminalToolDefinitions() []core.ToolDefinition {
 			ToolName:                 "send_money",
 			ToolDescription:          "Send money to another user. Requires confirmation.",
 			RequiresUserConfirmation: true,
-			SummaryTemplate:          "Send {{.amount}} {{.currency}} to {{.recipient}}",
+			SummaryTemplate:          "Send {{money .amount .currency}} to {{address .recipient}}{{if .note}} ({{.note}}){{end}}",
+			ActionType:               core.ActionTypeSend,
 			InputSchema: ObjectSchema(map[string]interface{}{
 				"recipient": StringProperty("Recipient's display tag (e.g., @alice) or user ID"),
 				"amount":    StringProperty("Amount to send (e.g., '50.00')"),
@@ -67,7 +68,8 @@ func LiminalToolDefinitions() []core.ToolDefinition {
 			ToolName:                 "deposit_savings",
 			ToolDescription:          "Deposit funds into savings. Requires confirmation.",
 			RequiresUserConfirmation: true,
-			SummaryTemplate:          "Deposit {{.amount}} {{.currency}} into savings",
+			SummaryTemplate:          "Deposit {{money .amount .currency}} into savings",
+			ActionType:               core.ActionTypeDeposit,
 			InputSchema: ObjectSchema(map[string]interface{}{
 				"amount":   StringProperty("Amount to deposit"),
 				"currency": StringProperty("Currency to deposit (e.g., 'USD', 'EUR', 'LIL')"),
@@ -77,7 +79,8 @@ func LiminalToolDefinitions() []core.ToolDefinition {
 			ToolName:                 "withdraw_savings",
 			ToolDescription:          "Withdraw funds from savings. Requires confirmation.",
 			RequiresUserConfirmation: true,
-			SummaryTemplate:          "Withdraw {{.amount}} {{.currency}} from savings",
+			SummaryTemplate:          "Withdraw {{money .amount .currency}} from savings",
+			ActionType:               core.ActionTypeWithdraw,
 			InputSchema: ObjectSchema(map[string]interface{}{
 				"amount":   StringProperty("Amount to withdraw"),
 				"currency": StringProperty("Currency to withdraw (e.g., 'USD', 'EUR', 'LIL')"),
@@ -90,7 +93,11 @@ func LiminalToolDefinitions() []core.ToolDefinition {
 func LiminalTools(executor core.ToolExecutor) []core.Tool {
 	definitions := LiminalToolDefinitions()
 	tools := make([]core.Tool, len(definitions))
+	preflight := LiminalPreflight(executor)
 	for i, def := range definitions {
+		if def.ActionType != "" {
+			def.Preflight = preflight
+		}
 		tools[i] = core.NewExecutorTool(def, executor)
 	}
 	return tools
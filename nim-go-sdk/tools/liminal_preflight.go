@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// LiminalPreflight returns a PreflightFunc that completes confirmation
+// details with Liminal reads: the recipient is resolved to a user ID with
+// search_users, and the wallet balance after the action is projected from
+// get_balance. Details that cannot be read are left empty.
+func LiminalPreflight(executor core.ToolExecutor) core.PreflightFunc {
+	return func(ctx context.Context, params *core.ToolParams, details *core.ActionDetails) error {
+		var errs []error
+
+		if details.Recipient != "" {
+			userID, err := resolveRecipient(ctx, executor, params, details.Recipient)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("resolve recipient: %w", err))
+			}
+			details.RecipientUserID = userID
+		}
+
+		if details.Amount != "" && details.Currency != "" {
+			balance, err := projectBalance(ctx, executor, params, details)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("project balance: %w", err))
+			}
+			details.ProjectedBalance = balance
+		}
+
+		return errors.Join(errs...)
+	}
+}
+
+// resolveRecipient finds the user ID of a display tag. Returns "" if no
+// user matches exactly.
+func resolveRecipient(ctx context.Context, executor core.ToolExecutor, params *core.ToolParams, recipient string) (string, error) {
+	var result struct {
+		Users []struct {
+			UserID     string `json:"userId"`
+			DisplayTag string `json:"displayTag"`
+		} `json:"users"`
+	}
+	if err := liminalRead(ctx, executor, params, "search_users", map[string]string{"query": recipient}, &result); err != nil {
+		return "", err
+	}
+
+	tag := strings.TrimPrefix(recipient, "@")
+	for _, user := range result.Users {
+		if strings.EqualFold(strings.TrimPrefix(user.DisplayTag, "@"), tag) {
+			return user.UserID, nil
+		}
+	}
+	return "", nil
+}
+
+// projectBalance returns the wallet balance in the action's currency after
+// the action. Sends and deposits leave the wallet, withdrawals from savings
+// arrive in it.
+func projectBalance(ctx context.Context, executor core.ToolExecutor, params *core.ToolParams, details *core.ActionDetails) (string, error) {
+	var sign int
+	switch details.ActionType {
+	case core.ActionTypeSend, core.ActionTypeDeposit:
+		sign = -1
+	case core.ActionTypeWithdraw:
+		sign = 1
+	default:
+		return "", nil
+	}
+
+	amount, ok := new(big.Rat).SetString(details.Amount)
+	if !ok {
+		return "", fmt.Errorf("invalid amount %q", details.Amount)
+	}
+
+	var result struct {
+		Balances []struct {
+			Currency string `json:"currency"`
+			Amount   string `json:"amount"`
+		} `json:"balances"`
+	}
+	if err := liminalRead(ctx, executor, params, "get_balance", map[string]string{"currency": details.Currency}, &result); err != nil {
+		return "", err
+	}
+
+	balance := new(big.Rat)
+	current := "0"
+	for _, b := range result.Balances {
+		if strings.EqualFold(b.Currency, details.Currency) {
+			if _, ok := balance.SetString(b.Amount); !ok {
+				return "", fmt.Errorf("invalid balance %q", b.Amount)
+			}
+			current = b.Amount
+			break
+		}
+	}
+
+	if sign < 0 {
+		balance.Sub(balance, amount)
+	} else {
+		balance.Add(balance, amount)
+	}
+	return balance.FloatString(max(decimalPlaces(current), decimalPlaces(details.Amount))), nil
+}
+
+// liminalRead runs a read-only Liminal tool and decodes its data into out.
+func liminalRead(ctx context.Context, executor core.ToolExecutor, params *core.ToolParams, tool string, input map[string]string, out interface{}) error {
+	inputBytes, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	resp, err := executor.Execute(ctx, &core.ExecuteRequest{
+		UserID:    params.UserID,
+		Tool:      tool,
+		Input:     inputBytes,
+		RequestID: params.RequestID,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s: %s", tool, resp.Error)
+	}
+	return json.Unmarshal(resp.Data, out)
+}
+
+// decimalPlaces returns the number of digits after the decimal point.
+func decimalPlaces(s string) int {
+	if _, frac, ok := strings.Cut(s, "."); ok {
+		return len(frac)
+	}
+	return 0
+}
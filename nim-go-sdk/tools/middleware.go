@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// Timeout fails a tool call that takes longer than d. The handler's context
+// is cancelled at the deadline; if the handler does not return promptly, its
+// result is discarded and a timeout failure is returned instead.
+//
+// Confirmed writes, which carry a ConfirmationID, are passed through without
+// a deadline: a write that has started always finishes, and reporting one
+// as failed while it may still complete would hide a money movement.
+//
+// The handler runs on its own goroutine, so place Recover after Timeout in
+// the chain to recover its panics.
+func Timeout(d time.Duration) core.ToolMiddleware {
+	return func(next core.ToolHandler) core.ToolHandler {
+		return func(ctx context.Context, params *core.ToolParams) (*core.ToolResult, error) {
+			if params.ConfirmationID != "" {
+				return next(ctx, params)
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			type outcome struct {
+				result *core.ToolResult
+				err    error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				result, err := next(ctx, params)
+				done <- outcome{result, err}
+			}()
+
+			select {
+			case o := <-done:
+				return o.result, o.err
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return &core.ToolResult{
+						Success: false,
+						Error:   fmt.Sprintf("tool timed out after %s", d),
+					}, nil
+				}
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// RetryConfig configures the Retry middleware.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry. It doubles after
+	// each attempt, up to MaxBackoff.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between attempts.
+	MaxBackoff time.Duration
+
+	// Retryable reports whether a failed attempt should be retried.
+	// Defaults to IsTransient.
+	Retryable func(result *core.ToolResult, err error) bool
+
+	// RetryWrites also retries confirmed writes. Enable it only for tools
+	// whose executor deduplicates by IdempotencyKey, since a transient
+	// error does not mean the write was not performed.
+	RetryWrites bool
+}
+
+// DefaultRetryConfig returns sensible defaults for Retry.
+func DefaultRetryConfig() *RetryConfig {
+	return &RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Retryable:      IsTransient,
+	}
+}
+
+// IsTransient treats errors returned by the handler as transient, for
+// example network failures reaching the executor. Failed results
+// (Success false) are answers from the tool and are not retried, and
+// neither is cancellation of the caller's context.
+func IsTransient(result *core.ToolResult, err error) bool {
+	if err == nil {
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// Retry retries transient failures with exponential backoff.
+// Confirmed writes, which carry a ConfirmationID, run once unless
+// RetryWrites is set; they are then retried with the same params, so
+// executors see the same IdempotencyKey on every attempt.
+func Retry(cfg *RetryConfig) core.ToolMiddleware {
+	if cfg == nil {
+		cfg = DefaultRetryConfig()
+	}
+	retryable := cfg.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+
+	return func(next core.ToolHandler) core.ToolHandler {
+		return func(ctx context.Context, params *core.ToolParams) (*core.ToolResult, error) {
+			if params.ConfirmationID != "" && !cfg.RetryWrites {
+				return next(ctx, params)
+			}
+
+			backoff := cfg.InitialBackoff
+			for attempt := 1; ; attempt++ {
+				result, err := next(ctx, params)
+				if attempt >= cfg.MaxAttempts || !retryable(result, err) {
+					return result, err
+				}
+
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return result, err
+				}
+				backoff *= 2
+				if cfg.MaxBackoff > 0 && backoff > cfg.MaxBackoff {
+					backoff = cfg.MaxBackoff
+				}
+			}
+		}
+	}
+}
+
+// Recover turns a panic in the handler into a failed ToolResult, so a
+// misbehaving tool cannot take down the server. Panics are logged with
+// slog.Default().
+func Recover() core.ToolMiddleware {
+	return func(next core.ToolHandler) core.ToolHandler {
+		return func(ctx context.Context, params *core.ToolParams) (result *core.ToolResult, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					slog.ErrorContext(ctx, "tool panicked",
+						slog.String("tool", params.ToolName),
+						slog.String("user_id", params.UserID),
+						slog.String("request_id", params.RequestID),
+						slog.Any("panic", r),
+						slog.String("stack", string(debug.Stack())),
+					)
+					result = &core.ToolResult{
+						Success: false,
+						Error:   fmt.Sprintf("tool failed unexpectedly: %v", r),
+					}
+					err = nil
+				}
+			}()
+			return next(ctx, params)
+		}
+	}
+}
+
+// Logging logs every tool call with its outcome and duration.
+// Tool input and output are not logged, as they may contain personal or
+// financial data. If logger is nil, slog.Default() is used.
+func Logging(logger *slog.Logger) core.ToolMiddleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next core.ToolHandler) core.ToolHandler {
+		return func(ctx context.Context, params *core.ToolParams) (*core.ToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, params)
+
+			attrs := []any{
+				slog.String("tool", params.ToolName),
+				slog.String("user_id", params.UserID),
+				slog.String("request_id", params.RequestID),
+				slog.Bool("confirmed", params.ConfirmationID != ""),
+				slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			}
+			switch {
+			case err != nil:
+				logger.ErrorContext(ctx, "tool call failed", append(attrs, slog.String("error", err.Error()))...)
+			case result == nil || !result.Success:
+				errMsg := ""
+				if result != nil {
+					errMsg = result.Error
+				}
+				logger.WarnContext(ctx, "tool call unsuccessful", append(attrs, slog.String("error", errMsg))...)
+			default:
+				logger.InfoContext(ctx, "tool call succeeded", attrs...)
+			}
+			return result, err
+		}
+	}
+}
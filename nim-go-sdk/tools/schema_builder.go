@@ -0,0 +1,224 @@
+package tools
+
+// Schema is a fluent JSON Schema builder. Build returns the same
+// map[string]interface{} shape as the helpers in schema.go, so built
+// schemas can be passed to Builder.Schema and mixed with existing helpers:
+//
+//	tools.Object().
+//		Property("recipient", tools.String().Description("Display tag").Pattern("^@")).
+//		Property("amount", tools.Number().ExclusiveMinimum(0)).
+//		Property("date", tools.String().Format("date").Nullable()).
+//		Property("memo", tools.Raw(tools.StringProperty("Optional note"))).
+//		Required("recipient", "amount").
+//		Build()
+type Schema struct {
+	keywords   map[string]interface{}
+	properties map[string]*Schema
+	required   []string
+	items      *Schema
+	additional *Schema
+	oneOf      []*Schema
+	anyOf      []*Schema
+	nullable   bool
+}
+
+func newSchema(schemaType string) *Schema {
+	s := &Schema{keywords: make(map[string]interface{})}
+	if schemaType != "" {
+		s.keywords["type"] = schemaType
+	}
+	return s
+}
+
+// String starts a string schema.
+func String() *Schema { return newSchema("string") }
+
+// Number starts a number schema.
+func Number() *Schema { return newSchema("number") }
+
+// Integer starts an integer schema.
+func Integer() *Schema { return newSchema("integer") }
+
+// Boolean starts a boolean schema.
+func Boolean() *Schema { return newSchema("boolean") }
+
+// Object starts an object schema.
+func Object() *Schema {
+	s := newSchema("object")
+	s.properties = make(map[string]*Schema)
+	return s
+}
+
+// Array starts an array schema with the given item schema.
+func Array(items *Schema) *Schema {
+	s := newSchema("array")
+	s.items = items
+	return s
+}
+
+// OneOf starts a schema matching exactly one of the given schemas.
+func OneOf(schemas ...*Schema) *Schema {
+	s := newSchema("")
+	s.oneOf = schemas
+	return s
+}
+
+// AnyOf starts a schema matching at least one of the given schemas.
+func AnyOf(schemas ...*Schema) *Schema {
+	s := newSchema("")
+	s.anyOf = schemas
+	return s
+}
+
+// Raw wraps an existing schema map, such as one returned by StringProperty,
+// so it can be used with the builder. The map is copied by Build.
+func Raw(schema map[string]interface{}) *Schema {
+	s := newSchema("")
+	for k, v := range schema {
+		s.keywords[k] = v
+	}
+	return s
+}
+
+// Description sets the description shown to Claude.
+func (s *Schema) Description(desc string) *Schema { return s.set("description", desc) }
+
+// Enum restricts the value to the given values.
+func (s *Schema) Enum(values ...interface{}) *Schema { return s.set("enum", values) }
+
+// Const restricts the value to a single value.
+func (s *Schema) Const(value interface{}) *Schema { return s.set("const", value) }
+
+// Default documents the value used when the field is omitted.
+func (s *Schema) Default(value interface{}) *Schema { return s.set("default", value) }
+
+// Format sets a string format, such as "date", "date-time" or "email".
+func (s *Schema) Format(format string) *Schema { return s.set("format", format) }
+
+// Pattern restricts a string to match a regular expression.
+func (s *Schema) Pattern(pattern string) *Schema { return s.set("pattern", pattern) }
+
+// MinLength sets the minimum string length.
+func (s *Schema) MinLength(n int) *Schema { return s.set("minLength", n) }
+
+// MaxLength sets the maximum string length.
+func (s *Schema) MaxLength(n int) *Schema { return s.set("maxLength", n) }
+
+// Minimum sets the inclusive lower bound of a number.
+func (s *Schema) Minimum(n float64) *Schema { return s.set("minimum", n) }
+
+// Maximum sets the inclusive upper bound of a number.
+func (s *Schema) Maximum(n float64) *Schema { return s.set("maximum", n) }
+
+// ExclusiveMinimum sets the exclusive lower bound of a number.
+func (s *Schema) ExclusiveMinimum(n float64) *Schema { return s.set("exclusiveMinimum", n) }
+
+// ExclusiveMaximum sets the exclusive upper bound of a number.
+func (s *Schema) ExclusiveMaximum(n float64) *Schema { return s.set("exclusiveMaximum", n) }
+
+// MinItems sets the minimum array length.
+func (s *Schema) MinItems(n int) *Schema { return s.set("minItems", n) }
+
+// MaxItems sets the maximum array length.
+func (s *Schema) MaxItems(n int) *Schema { return s.set("maxItems", n) }
+
+// Property adds an object property.
+func (s *Schema) Property(name string, prop *Schema) *Schema {
+	if s.properties == nil {
+		s.properties = make(map[string]*Schema)
+	}
+	s.properties[name] = prop
+	return s
+}
+
+// Required marks object properties as required.
+func (s *Schema) Required(names ...string) *Schema {
+	s.required = append(s.required, names...)
+	return s
+}
+
+// AdditionalProperties sets the schema for object properties not listed
+// with Property.
+func (s *Schema) AdditionalProperties(schema *Schema) *Schema {
+	s.additional = schema
+	return s
+}
+
+// Strict rejects object properties not listed with Property.
+func (s *Schema) Strict() *Schema { return s.set("additionalProperties", false) }
+
+// Nullable also allows null.
+func (s *Schema) Nullable() *Schema {
+	s.nullable = true
+	return s
+}
+
+func (s *Schema) set(key string, value interface{}) *Schema {
+	s.keywords[key] = value
+	return s
+}
+
+// Build returns the schema as a map, in the shape core.Tool.Schema returns.
+func (s *Schema) Build() map[string]interface{} {
+	schema := make(map[string]interface{}, len(s.keywords)+4)
+	for k, v := range s.keywords {
+		schema[k] = v
+	}
+
+	if s.properties != nil {
+		properties := make(map[string]interface{}, len(s.properties))
+		for name, prop := range s.properties {
+			properties[name] = prop.Build()
+		}
+		schema["properties"] = properties
+	}
+	if len(s.required) > 0 {
+		schema["required"] = append([]string(nil), s.required...)
+	}
+	if s.items != nil {
+		schema["items"] = s.items.Build()
+	}
+	if s.additional != nil {
+		schema["additionalProperties"] = s.additional.Build()
+	}
+	if len(s.oneOf) > 0 {
+		schema["oneOf"] = buildAll(s.oneOf)
+	}
+	if len(s.anyOf) > 0 {
+		schema["anyOf"] = buildAll(s.anyOf)
+	}
+
+	if s.nullable {
+		makeNullable(schema)
+	}
+	return schema
+}
+
+func buildAll(schemas []*Schema) []interface{} {
+	built := make([]interface{}, len(schemas))
+	for i, schema := range schemas {
+		built[i] = schema.Build()
+	}
+	return built
+}
+
+// makeNullable allows null in a built schema: typed schemas gain "null" in
+// their type list, and composed schemas gain a null alternative.
+func makeNullable(schema map[string]interface{}) {
+	switch t := schema["type"].(type) {
+	case string:
+		schema["type"] = []string{t, "null"}
+	case []string:
+		schema["type"] = append(append([]string(nil), t...), "null")
+	default:
+		null := map[string]interface{}{"type": "null"}
+		if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+			schema["oneOf"] = append(oneOf, null)
+		} else if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+			schema["anyOf"] = append(anyOf, null)
+		}
+	}
+	if enum, ok := schemaList(schema["enum"]); ok {
+		schema["enum"] = append(enum, nil)
+	}
+}
@@ -13,6 +13,9 @@ const ThinkToolName = "think"
 // ThinkTool allows the agent to think through problems step by step.
 // This tool has no side effects and simply acknowledges the thought.
 // It's useful for complex reasoning and planning.
+//
+// On models that support extended thinking, set a thinking budget
+// (engine.Input.ThinkingBudget or core.Capabilities.ThinkingBudget) instead.
 type ThinkTool struct{}
 
 // NewThinkTool creates a new think tool.
@@ -0,0 +1,251 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// Typed creates a tool builder from a typed handler. The input schema is
+// derived from In's struct fields, the input is validated and decoded into
+// In before fn runs, and fn's Out is returned as the result data.
+//
+// Fields are named by their json tag and described with struct tags:
+//
+//	type SendParams struct {
+//		Recipient string   `json:"recipient" description:"Display tag, e.g. @alice" jsonschema:"required" pattern:"^@"`
+//		Amount    float64  `json:"amount" description:"Amount to send" jsonschema:"required,exclusiveMinimum=0"`
+//		Currency  string   `json:"currency" jsonschema:"required,enum=USD|EUR|LIL"`
+//		Tags      []string `json:"tags,omitempty" jsonschema:"maxItems=5"`
+//	}
+//
+// The jsonschema tag accepts required, enum (values separated by |),
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, minLength,
+// maxLength, minItems, maxItems, format and default. Nested structs, slices
+// and maps are described recursively.
+func Typed[In, Out any](name string, fn func(ctx context.Context, input In) (Out, error)) *Builder {
+	schema := SchemaFor[In]()
+
+	return New(name).
+		Schema(schema).
+		Handler(func(ctx context.Context, params *core.ToolParams) (*core.ToolResult, error) {
+			if err := ValidateInput(schema, params.Input); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			var input In
+			if len(params.Input) > 0 {
+				if err := json.Unmarshal(params.Input, &input); err != nil {
+					return &core.ToolResult{
+						Success: false,
+						Error:   fmt.Sprintf("invalid input: %v", err),
+					}, nil
+				}
+			}
+
+			output, err := fn(ctx, input)
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			return &core.ToolResult{Success: true, Data: output}, nil
+		})
+}
+
+// SchemaFor returns the JSON Schema for T, which should be a struct.
+// See Typed for the supported struct tags.
+func SchemaFor[T any]() map[string]interface{} {
+	return schemaForType(reflect.TypeOf((*T)(nil)).Elem(), nil)
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaForType builds the schema for t. seen guards against recursive types.
+func schemaForType(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json reads and writes []byte as a base64 string
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaForType(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaForType(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			// Recursive type: allow any object below this point
+			return map[string]interface{}{"type": "object"}
+		}
+		if seen == nil {
+			seen = make(map[reflect.Type]bool)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return structSchema(t, seen)
+	}
+
+	// Interfaces and other kinds accept any value
+	return map[string]interface{}{}
+}
+
+// structSchema builds an object schema from a struct's exported fields.
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	addStructFields(t, seen, properties, &required)
+	return ObjectSchema(properties, required...)
+}
+
+// addStructFields adds a struct's fields to properties. Embedded structs
+// without a json name are flattened, as encoding/json does.
+func addStructFields(t reflect.Type, seen map[reflect.Type]bool, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addStructFields(embedded, seen, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := schemaForType(field.Type, seen)
+		if desc := field.Tag.Get("description"); desc != "" {
+			prop["description"] = desc
+		}
+		if pattern := field.Tag.Get("pattern"); pattern != "" {
+			prop["pattern"] = pattern
+		}
+		if applySchemaTag(prop, field.Tag.Get("jsonschema")) {
+			*required = append(*required, name)
+		}
+		properties[name] = prop
+	}
+}
+
+// jsonFieldName returns the field's json name, empty if it has none, and
+// false if the field is skipped with json:"-".
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, true
+}
+
+// applySchemaTag applies the options of a jsonschema struct tag to prop.
+// It reports whether the field is required.
+func applySchemaTag(prop map[string]interface{}, tag string) bool {
+	required := false
+	if tag == "" {
+		return required
+	}
+
+	for _, option := range strings.Split(tag, ",") {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "required":
+			required = true
+		case "enum":
+			values := strings.Split(value, "|")
+			if enum := typedValues(prop["type"], values); enum != nil {
+				prop["enum"] = enum
+			} else {
+				prop["enum"] = values
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				prop[key] = n
+			}
+		case "minLength", "maxLength", "minItems", "maxItems":
+			if n, err := strconv.Atoi(value); err == nil {
+				prop[key] = n
+			}
+		case "format":
+			prop["format"] = value
+		case "default":
+			if hasValue {
+				prop["default"] = typedValue(prop["type"], value)
+			}
+		}
+	}
+	return required
+}
+
+// typedValues converts enum values for numeric and boolean fields.
+// Returns nil for string fields, whose values are used as is.
+func typedValues(schemaType interface{}, values []string) []interface{} {
+	if schemaType == "string" || schemaType == nil {
+		return nil
+	}
+	typed := make([]interface{}, len(values))
+	for i, v := range values {
+		typed[i] = typedValue(schemaType, v)
+	}
+	return typed
+}
+
+// typedValue converts a tag value to the field's JSON type.
+func typedValue(schemaType interface{}, value string) interface{} {
+	switch schemaType {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
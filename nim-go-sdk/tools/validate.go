@@ -0,0 +1,408 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ValidationError describes one way a tool input violates its schema.
+type ValidationError struct {
+	// Field is the path to the offending value, such as "amount" or
+	// "items[0].price". Empty for the input as a whole.
+	Field string
+
+	// Message describes the problem, such as "expected string".
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors is every problem found in a tool input.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateInput checks a tool input against its JSON Schema. It supports
+// type, required, properties, additionalProperties, items, enum, const,
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, minLength,
+// maxLength, pattern, format (date, date-time, email), minItems, maxItems,
+// oneOf, anyOf and allOf. Other keywords are ignored.
+// Returns ValidationErrors describing every problem, or nil.
+func ValidateInput(schema map[string]interface{}, input json.RawMessage) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	var value interface{}
+	if len(bytes.TrimSpace(input)) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(input))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return ValidationErrors{{Message: fmt.Sprintf("invalid JSON: %v", err)}}
+		}
+	} else {
+		value = map[string]interface{}{}
+	}
+
+	var errs ValidationErrors
+	validateValue(schema, value, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateValue checks value against schema, appending problems to errs.
+func validateValue(schema map[string]interface{}, value interface{}, path string, errs *ValidationErrors) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, &ValidationError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesAnyType(value, types) {
+		fail("expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return
+	}
+
+	if allowed, ok := schemaList(schema["enum"]); ok && !containsValue(allowed, value) {
+		fail("must be one of %s", formatValues(allowed))
+	}
+	if expected, ok := schema["const"]; ok && !equalValues(expected, value) {
+		fail("must be %s", formatValues([]interface{}{expected}))
+	}
+
+	if alternatives, ok := schemaList(schema["anyOf"]); ok && countMatches(alternatives, value) == 0 {
+		fail("does not match any of the allowed schemas")
+	}
+	if alternatives, ok := schemaList(schema["oneOf"]); ok {
+		if n := countMatches(alternatives, value); n != 1 {
+			fail("must match exactly one of the allowed schemas, matched %d", n)
+		}
+	}
+	if all, ok := schemaList(schema["allOf"]); ok {
+		for _, sub := range all {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				validateValue(subSchema, value, path, errs)
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, v, path, errs)
+	case []interface{}:
+		validateArray(schema, v, path, errs)
+	case string:
+		validateString(schema, v, fail)
+	case json.Number:
+		validateNumber(schema, v, fail)
+	}
+}
+
+// countMatches returns how many of the schemas value satisfies.
+func countMatches(schemas []interface{}, value interface{}) int {
+	n := 0
+	for _, s := range schemas {
+		schema, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var errs ValidationErrors
+		validateValue(schema, value, "", &errs)
+		if len(errs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func validateObject(schema map[string]interface{}, obj map[string]interface{}, path string, errs *ValidationErrors) {
+	required, _ := schemaList(schema["required"])
+	for _, name := range required {
+		field, _ := name.(string)
+		if _, ok := obj[field]; !ok {
+			*errs = append(*errs, &ValidationError{Field: joinPath(path, field), Message: "is required"})
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	additional := schema["additionalProperties"]
+
+	// Check fields in a stable order so error messages are deterministic
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fieldPath := joinPath(path, name)
+		if propSchema, ok := properties[name].(map[string]interface{}); ok {
+			validateValue(propSchema, obj[name], fieldPath, errs)
+			continue
+		}
+		if _, ok := properties[name]; ok {
+			continue
+		}
+		switch add := additional.(type) {
+		case bool:
+			if !add {
+				*errs = append(*errs, &ValidationError{Field: fieldPath, Message: "unknown field"})
+			}
+		case map[string]interface{}:
+			validateValue(add, obj[name], fieldPath, errs)
+		}
+	}
+}
+
+func validateArray(schema map[string]interface{}, arr []interface{}, path string, errs *ValidationErrors) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, &ValidationError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if min, ok := schemaNumber(schema["minItems"]); ok && float64(len(arr)) < min {
+		fail("must have at least %s items", formatNumber(min))
+	}
+	if max, ok := schemaNumber(schema["maxItems"]); ok && float64(len(arr)) > max {
+		fail("must have at most %s items", formatNumber(max))
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range arr {
+			validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func validateString(schema map[string]interface{}, s string, fail func(string, ...interface{})) {
+	length := float64(len([]rune(s)))
+	if min, ok := schemaNumber(schema["minLength"]); ok && length < min {
+		fail("must be at least %s characters", formatNumber(min))
+	}
+	if max, ok := schemaNumber(schema["maxLength"]); ok && length > max {
+		fail("must be at most %s characters", formatNumber(max))
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := compilePattern(pattern)
+		if err == nil && !re.MatchString(s) {
+			fail("must match pattern %s", pattern)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !validFormat(format, s) {
+		fail("must be a valid %s", format)
+	}
+}
+
+// validFormat checks the string formats Claude is most often asked for.
+// Unknown formats are accepted.
+func validFormat(format, s string) bool {
+	switch format {
+	case "date":
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	}
+	return true
+}
+
+func validateNumber(schema map[string]interface{}, n json.Number, fail func(string, ...interface{})) {
+	f, err := n.Float64()
+	if err != nil {
+		fail("invalid number")
+		return
+	}
+	if min, ok := schemaNumber(schema["minimum"]); ok && f < min {
+		fail("must be at least %s", formatNumber(min))
+	}
+	if max, ok := schemaNumber(schema["maximum"]); ok && f > max {
+		fail("must be at most %s", formatNumber(max))
+	}
+	if min, ok := schemaNumber(schema["exclusiveMinimum"]); ok && f <= min {
+		fail("must be greater than %s", formatNumber(min))
+	}
+	if max, ok := schemaNumber(schema["exclusiveMaximum"]); ok && f >= max {
+		fail("must be less than %s", formatNumber(max))
+	}
+}
+
+// jsonType returns the JSON Schema type name of a decoded value.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if isInteger(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// matchesAnyType reports whether value is one of the JSON Schema types.
+// Integers are also numbers.
+func matchesAnyType(value interface{}, types []string) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func isInteger(n json.Number) bool {
+	if _, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return true
+	}
+	f, err := n.Float64()
+	return err == nil && f == math.Trunc(f) && !math.IsInf(f, 0)
+}
+
+// schemaTypes reads a "type" keyword, which may be a string or a list.
+func schemaTypes(v interface{}) []string {
+	if s, ok := v.(string); ok {
+		return []string{s}
+	}
+	list, _ := schemaList(v)
+	types := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			types = append(types, s)
+		}
+	}
+	return types
+}
+
+// schemaList reads a list keyword. Schemas built in Go use typed slices
+// such as []string, while schemas decoded from JSON use []interface{}.
+func schemaList(v interface{}) ([]interface{}, bool) {
+	switch list := v.(type) {
+	case []interface{}:
+		return list, true
+	case []string:
+		out := make([]interface{}, len(list))
+		for i, s := range list {
+			out[i] = s
+		}
+		return out, true
+	case []int:
+		out := make([]interface{}, len(list))
+		for i, n := range list {
+			out[i] = n
+		}
+		return out, true
+	case []float64:
+		out := make([]interface{}, len(list))
+		for i, n := range list {
+			out[i] = n
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// schemaNumber reads a numeric keyword.
+func schemaNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// containsValue reports whether value equals one of the allowed values.
+func containsValue(allowed []interface{}, value interface{}) bool {
+	for _, a := range allowed {
+		if equalValues(a, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalValues compares a schema value with a decoded input value.
+func equalValues(schemaValue, value interface{}) bool {
+	if n, ok := value.(json.Number); ok {
+		expected, ok := schemaNumber(schemaValue)
+		actual, err := n.Float64()
+		return ok && err == nil && expected == actual
+	}
+	a, errA := json.Marshal(schemaValue)
+	b, errB := json.Marshal(value)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+func formatValues(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		b, _ := json.Marshal(v)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// patterns caches compiled schema patterns.
+var patterns sync.Map // pattern -> *regexp.Regexp
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}